@@ -2,12 +2,23 @@
 
 export PASSWORD="${PASSWORD}"
 
+# The password file starts as a copy of the one mounted from a Kubernetes secret,
+# elsewhere it is created from the environment. x11vnc re-reads it, so the API
+# rotates the password in place by overwriting the copy.
+export PASSWORD_FILE=/tmp/tit-dashboard-password
+
+if [ -f /etc/tit-dashboard/password ]; then
+    cp /etc/tit-dashboard/password "${PASSWORD_FILE}"
+else
+    printf '%s\n' "${PASSWORD}" > "${PASSWORD_FILE}"
+fi
+
 Xvfb :0 -screen 0 1288x724x24 &
 
 fluxbox -display :0 &
 fluxbox_pid=$!
 
-x11vnc -display :0 -forever -passwd "${PASSWORD}" &
+x11vnc -display :0 -forever -passwdfile "read:${PASSWORD_FILE}" &
 
 websockify 0.0.0.0:8888 localhost:5900 &
 
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -33,6 +34,14 @@ func Run() {
 		return
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go services.NewDashboardReaper(dashboardService, log, conf).Run(ctx)
+	go services.NewDashboardReconciler(dashboardService, log, conf).Run(ctx)
+	go services.NewDashboardWarmPool(dashboardService, log, conf).Run(ctx)
+	go services.NewDashboardEventWatcher(dashboardService, log).Run(ctx)
+
 	app := fiber.New(fiber.Config{
 		ErrorHandler: middleware.NewErrorHandlerMiddleware(),
 	})
@@ -48,10 +57,18 @@ func Run() {
 
 	app.Mount("/auth", controllers.NewAuthController(db, log, userService, conf))
 	app.Mount("/api/whoami", controllers.NewWhoAmIController(db, conf, log, userService))
-	app.Mount("/api/dashboard", controllers.NewDashboardController(
+	app.Mount("/api/dashboard", controllers.NewCurrentDashboardController(
+		db, log, userService, dashboardService, conf,
+	))
+	app.Mount("/api/dashboards", controllers.NewDashboardController(
 		db, log, userService, dashboardService, conf,
 	))
 	app.Mount("/api/tutorials", controllers.NewTutorialsController(db, conf, log, userService))
+	app.Mount("/api/dashboard-templates", controllers.NewDashboardTemplatesController(db, conf, log))
+	app.Mount("/api/admin", controllers.NewAdminController(db, conf, log, dashboardService))
+	app.Mount("/api/instructor", controllers.NewInstructorController(
+		db, conf, log, userService, dashboardService,
+	))
 
 	address := fmt.Sprintf(":%d", conf.Port)
 
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/middleware"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+type adminController struct {
+	db               *core.Database
+	logger           *core.Logger
+	dashboardService *services.DashboardService
+}
+
+// user returns the user the route parameter key points at.
+func (a *adminController) user(c *fiber.Ctx, key string) (*models.User, error) {
+	user := new(models.User)
+
+	id, err := c.ParamsInt(key)
+	if err != nil {
+		return nil, fiber.ErrNotFound
+	}
+
+	user.ID = uint64(id)
+
+	err = a.db.NewSelect().Model(user).WherePK().Scan(c.UserContext())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fiber.ErrNotFound
+	}
+
+	if err != nil {
+		a.logger.Err(err).Msg("admin user selecting")
+
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return user, nil
+}
+
+func (a *adminController) wipeHomeVolume() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := a.user(c, "id")
+		if err != nil {
+			return err
+		}
+
+		err = a.dashboardService.WipeHomeVolume(c.UserContext(), user)
+		switch {
+		case errors.Is(err, services.ErrHomeVolumeNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrHomeVolumesDisabled):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case err != nil:
+			a.logger.Err(err).Msg("home volume wiping")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "home volume wiped successfully",
+		})
+	}
+}
+
+// setInstructor grants or revokes the instructor role of the user.
+func (a *adminController) setInstructor(isInstructor bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := a.user(c, "id")
+		if err != nil {
+			return err
+		}
+
+		user.IsInstructor = isInstructor
+
+		_, err = a.db.NewUpdate().
+			Model(user).
+			Column("is_instructor").
+			WherePK().
+			Exec(c.UserContext())
+		if err != nil {
+			a.logger.Err(err).Msg("instructor role updating")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(user)
+	}
+}
+
+func (a *adminController) listDashboardAccessLogs() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var studentID uint64
+
+		if query := c.Query("studentId"); query != "" {
+			var err error
+
+			if studentID, err = strconv.ParseUint(query, 10, 64); err != nil {
+				return fiber.ErrBadRequest
+			}
+		}
+
+		logs, err := a.dashboardService.ListDashboardAccessLogs(c.UserContext(), studentID)
+		if err != nil {
+			a.logger.Err(err).Msg("list dashboard access logs")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(logs)
+	}
+}
+
+func (a *adminController) listDashboards() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		pods, err := a.dashboardService.ListDashboardPods(c.UserContext())
+		if err != nil {
+			a.logger.Err(err).Msg("list dashboard pods")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(pods)
+	}
+}
+
+func (a *adminController) forceStopDashboards() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := a.user(c, "userId")
+		if err != nil {
+			return err
+		}
+
+		dashboards, err := a.dashboardService.ForceStopUserDashboards(c.UserContext(), user)
+		if err != nil {
+			a.logger.Err(err).Uint64("userId", user.ID).Msg("dashboards force stopping")
+
+			return fiber.ErrInternalServerError
+		}
+
+		if len(dashboards) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": services.ErrDashboardNotRunning.Error(),
+			})
+		}
+
+		return c.JSON(dashboards)
+	}
+}
+
+// setDashboardQuota overrides the monthly dashboard quota of the user, a null
+// quota restores the configured one.
+func (a *adminController) setDashboardQuota() fiber.Handler {
+	type request struct {
+		Hours *int `json:"hours"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		requestData := new(request)
+
+		if err := c.BodyParser(requestData); err != nil {
+			return err
+		}
+
+		if requestData.Hours != nil && *requestData.Hours < 0 {
+			return fiber.ErrBadRequest
+		}
+
+		user, err := a.user(c, "id")
+		if err != nil {
+			return err
+		}
+
+		user.DashboardQuotaHours = requestData.Hours
+
+		_, err = a.db.NewUpdate().
+			Model(user).
+			Column("dashboard_quota_hours").
+			WherePK().
+			Exec(c.UserContext())
+		if err != nil {
+			a.logger.Err(err).Msg("dashboard quota updating")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(user)
+	}
+}
+
+func (a *adminController) listDashboardUsage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, to, err := usageMonth(c)
+		if err != nil {
+			return err
+		}
+
+		usage, err := a.dashboardService.ListDashboardUsage(c.UserContext(), from, to)
+		if err != nil {
+			a.logger.Err(err).Msg("list dashboard usage")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(usage)
+	}
+}
+
+func (a *adminController) metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"dashboardReconciler": services.ReconcilerMetrics(),
+		})
+	}
+}
+
+func NewAdminController(
+	db *core.Database,
+	conf *core.Config,
+	logger *core.Logger,
+	dashboardService *services.DashboardService,
+) *fiber.App {
+	controller := adminController{db, logger, dashboardService}
+
+	app := fiber.New()
+
+	app.Use(middleware.NewRequireAuth(conf))
+	app.Use(middleware.NewIsActive(db, logger))
+	app.Use(middleware.NewIsSuperUser(db, logger))
+
+	app.Delete("/users/:id/home-volume", controller.wipeHomeVolume())
+	app.Put("/users/:id/instructor", controller.setInstructor(true))
+	app.Delete("/users/:id/instructor", controller.setInstructor(false))
+	app.Put("/users/:id/dashboard-quota", controller.setDashboardQuota())
+	app.Get("/dashboard-access-logs", controller.listDashboardAccessLogs())
+	app.Get("/dashboards", controller.listDashboards())
+	app.Delete("/dashboards/:userId", controller.forceStopDashboards())
+	app.Get("/dashboard-usage", controller.listDashboardUsage())
+	app.Get("/metrics", controller.metrics())
+
+	return app
+}
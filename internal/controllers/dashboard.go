@@ -1,13 +1,29 @@
 package controllers
 
 import (
+	"bufio"
+	"errors"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/tutorin-tech/tit-backend/internal/core"
 	"github.com/tutorin-tech/tit-backend/internal/middleware"
+	"github.com/tutorin-tech/tit-backend/internal/models"
 	"github.com/tutorin-tech/tit-backend/internal/services"
 )
 
+const (
+	// dashboardQueueRetryAfter is how many seconds queued clients wait before asking again.
+	dashboardQueueRetryAfter = "5"
+	// dashboardHomeVolumeRetryAfter is how many seconds clients wait for the wiped
+	// home volume to be deleted before starting the dashboard again.
+	dashboardHomeVolumeRetryAfter = "10"
+)
+
+// dashboardLookup finds the dashboard of the current user a route acts on.
+type dashboardLookup func(c *fiber.Ctx) (*models.Dashboard, error)
+
 type dashboardController struct {
 	db               *core.Database
 	logger           *core.Logger
@@ -15,33 +31,320 @@ type dashboardController struct {
 	dashboardService *services.DashboardService
 }
 
-func (d *dashboardController) dashboard() fiber.Handler {
+func (d *dashboardController) currentUser(c *fiber.Ctx) (*models.User, error) {
+	token, _ := c.Locals("user").(*jwt.Token)
+
+	user, err := d.userService.GetUserByToken(c.UserContext(), token)
+	if err != nil || user == nil {
+		d.logger.Err(err).Msg("dashboard user selecting")
+
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return user, nil
+}
+
+// userDashboard returns the dashboard of the current user the route points at.
+func (d *dashboardController) userDashboard(c *fiber.Ctx) (*models.Dashboard, error) {
+	user, err := d.currentUser(c)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, fiber.ErrNotFound
+	}
+
+	dashboard, err := d.dashboardService.GetDashboard(c.UserContext(), user, uint64(id))
+	if errors.Is(err, services.ErrDashboardNotFound) {
+		return nil, fiber.ErrNotFound
+	}
+
+	if err != nil {
+		d.logger.Err(err).Msg("dashboard selecting")
+
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return dashboard, nil
+}
+
+// currentDashboard returns the latest running dashboard of the current user.
+func (d *dashboardController) currentDashboard(c *fiber.Ctx) (*models.Dashboard, error) {
+	user, err := d.currentUser(c)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard, err := d.dashboardService.GetCurrentDashboard(c.UserContext(), user)
+	if errors.Is(err, services.ErrDashboardNotFound) {
+		return nil, fiber.ErrNotFound
+	}
+
+	if err != nil {
+		d.logger.Err(err).Msg("current dashboard selecting")
+
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return dashboard, nil
+}
+
+func (d *dashboardController) listDashboards() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		token, _ := c.Locals("user").(*jwt.Token)
+		user, err := d.currentUser(c)
+		if err != nil {
+			return err
+		}
 
-		user, err := d.userService.GetUserByToken(c.UserContext(), token)
-		if err != nil || user == nil {
-			d.logger.Err(err).Msg("dashboard user selecting")
+		dashboards, err := d.dashboardService.ListDashboards(c.UserContext(), user)
+		if err != nil {
+			d.logger.Err(err).Msg("list dashboards")
 
 			return fiber.ErrInternalServerError
 		}
 
-		isDashboardRunning := d.dashboardService.IsDashboardRunning(c.UserContext(), user)
-		if !isDashboardRunning {
-			if err := d.dashboardService.StartDashboard(c.UserContext(), user); err != nil {
-				d.logger.Err(err).Msg("dashboard starting")
+		return c.JSON(dashboards)
+	}
+}
 
-				return fiber.ErrInternalServerError
+func (d *dashboardController) createDashboard() fiber.Handler {
+	type request struct {
+		TutorialID uint64 `json:"tutorialId"`
+	}
+
+	return func(c *fiber.Ctx) error {
+		requestData := new(request)
+
+		if len(c.Body()) != 0 {
+			if err := c.BodyParser(requestData); err != nil {
+				return err
 			}
 		}
 
+		user, err := d.currentUser(c)
+		if err != nil {
+			return err
+		}
+
+		opts := services.DashboardStartOptions{
+			WaitReady:  c.Query("wait") == "true",
+			TutorialID: requestData.TutorialID,
+		}
+
+		var queued *services.DashboardQueuedError
+
+		dashboard, err := d.dashboardService.CreateDashboard(c.UserContext(), user, opts)
+		switch {
+		case errors.As(err, &queued):
+			// Asking again keeps the place in the queue and starts the dashboard once it is the turn.
+			c.Set(fiber.HeaderRetryAfter, dashboardQueueRetryAfter)
+
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+				"error":    services.ErrDashboardQueued.Error(),
+				"position": queued.Position,
+			})
+		case errors.Is(err, services.ErrTutorialNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrDashboardQuotaExceeded):
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrDashboardLimitReached):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrHomeVolumeTerminating):
+			c.Set(fiber.HeaderRetryAfter, dashboardHomeVolumeRetryAfter)
+
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrDashboardReadyTimeout):
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, services.ErrDashboardFailed):
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case err != nil:
+			d.logger.Err(err).Msg("dashboard starting")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(dashboard)
+	}
+}
+
+func (d *dashboardController) getDashboard(lookup dashboardLookup) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dashboard, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(dashboard)
+	}
+}
+
+func (d *dashboardController) status(lookup dashboardLookup) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dashboard, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		status, err := d.dashboardService.GetDashboardStatus(c.UserContext(), dashboard)
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard status checking")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(status)
+	}
+}
+
+func (d *dashboardController) rotatePassword(lookup dashboardLookup) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dashboard, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		err = d.dashboardService.RotateDashboardPassword(c.UserContext(), dashboard)
+		if errors.Is(err, services.ErrDashboardNotRunning) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard password rotation")
+
+			return fiber.ErrInternalServerError
+		}
+
 		return c.JSON(fiber.Map{
-			"id":       user.ID,
-			"password": user.DashboardPassword,
+			"id":       dashboard.ID,
+			"password": dashboard.Password,
 		})
 	}
 }
 
+func (d *dashboardController) heartbeat(lookup dashboardLookup) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dashboard, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		if dashboard.Status == models.DashboardStopped {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": services.ErrDashboardNotRunning.Error(),
+			})
+		}
+
+		if err := d.dashboardService.TouchDashboard(c.UserContext(), dashboard); err != nil {
+			d.logger.Err(err).Msg("dashboard activity update")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func (d *dashboardController) stopDashboard(lookup dashboardLookup) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dashboard, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		result, err := d.dashboardService.StopDashboard(c.UserContext(), dashboard)
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard stopping")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(result)
+	}
+}
+
+// usageMonth returns the month given by the "month" query parameter in the
+// YYYY-MM form, the current month by default.
+func usageMonth(c *fiber.Ctx) (time.Time, time.Time, error) {
+	month := time.Now()
+
+	if query := c.Query("month"); query != "" {
+		var err error
+
+		if month, err = time.Parse("2006-01", query); err != nil {
+			return time.Time{}, time.Time{}, fiber.ErrBadRequest
+		}
+	}
+
+	from, to := services.DashboardUsageMonth(month)
+
+	return from, to, nil
+}
+
+func (d *dashboardController) usage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := d.currentUser(c)
+		if err != nil {
+			return err
+		}
+
+		from, to, err := usageMonth(c)
+		if err != nil {
+			return err
+		}
+
+		usage, err := d.dashboardService.GetDashboardUsage(c.UserContext(), user, from, to)
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard usage selecting")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(usage)
+	}
+}
+
+// events streams the lifecycle events of the dashboards of the user as server-sent
+// events until the client goes away.
+func (d *dashboardController) events() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := d.currentUser(c)
+		if err != nil {
+			return err
+		}
+
+		events, unsubscribe := d.dashboardService.SubscribeDashboardEvents(user)
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			streamDashboardEvents(w, events, dashboardEventsKeepAlive)
+		})
+
+		return nil
+	}
+}
+
+// NewDashboardController serves the dashboards of the current user by their IDs.
 func NewDashboardController(
 	db *core.Database,
 	logger *core.Logger,
@@ -58,10 +361,63 @@ func NewDashboardController(
 
 	app := fiber.New()
 
+	// Registered ahead of the common middlewares, which only take the JWT from headers.
+	app.Get(
+		"/:id/ws",
+		middleware.NewRequireQueryAuth(conf),
+		middleware.NewIsActive(db, logger),
+		newDashboardProxy(logger, dashboardService, controller.userDashboard, true),
+	)
+
 	app.Use(middleware.NewRequireAuth(conf))
 	app.Use(middleware.NewIsActive(db, logger))
 
-	app.Post("/", controller.dashboard())
+	app.Get("/", controller.listDashboards())
+	app.Post("/", controller.createDashboard())
+	app.Get("/:id", controller.getDashboard(controller.userDashboard))
+	app.Delete("/:id", controller.stopDashboard(controller.userDashboard))
+	app.Get("/:id/status", controller.status(controller.userDashboard))
+	app.Post("/:id/heartbeat", controller.heartbeat(controller.userDashboard))
+	app.Post("/:id/rotate-password", controller.rotatePassword(controller.userDashboard))
+
+	return app
+}
+
+// NewCurrentDashboardController serves the latest running dashboard of the current
+// user, along with the events and the usage of all of their dashboards.
+func NewCurrentDashboardController(
+	db *core.Database,
+	logger *core.Logger,
+	userService *services.UserService,
+	dashboardService *services.DashboardService,
+	conf *core.Config,
+) *fiber.App {
+	controller := dashboardController{
+		db,
+		logger,
+		userService,
+		dashboardService,
+	}
+
+	app := fiber.New()
+
+	// Middlewares are set by route, as app.Use would match the paths of
+	// "/api/dashboards" and "/api/dashboard-templates" as well once mounted.
+	requireAuth := middleware.NewRequireAuth(conf)
+	requireQueryAuth := middleware.NewRequireQueryAuth(conf)
+	isActive := middleware.NewIsActive(db, logger)
+
+	app.Get("/ws", requireQueryAuth, isActive,
+		newDashboardProxy(logger, dashboardService, controller.currentDashboard, true))
+	app.Get("/events", requireQueryAuth, isActive, controller.events())
+	app.Get("/usage", requireAuth, isActive, controller.usage())
+
+	app.Get("/", requireAuth, isActive, controller.getDashboard(controller.currentDashboard))
+	app.Post("/", requireAuth, isActive, controller.createDashboard())
+	app.Delete("/", requireAuth, isActive, controller.stopDashboard(controller.currentDashboard))
+	app.Get("/status", requireAuth, isActive, controller.status(controller.currentDashboard))
+	app.Post("/heartbeat", requireAuth, isActive, controller.heartbeat(controller.currentDashboard))
+	app.Post("/rotate-password", requireAuth, isActive, controller.rotatePassword(controller.currentDashboard))
 
 	return app
 }
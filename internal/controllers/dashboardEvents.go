@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+// dashboardEventsKeepAlive is how often a comment is written to an idle event
+// stream, so that proxies keep it open and gone clients are noticed.
+const dashboardEventsKeepAlive = 15 * time.Second
+
+// streamDashboardEvents writes the events to w in the server-sent events format
+// until the channel is closed or the client goes away.
+func streamDashboardEvents(w *bufio.Writer, events <-chan services.DashboardEvent, keepAlive time.Duration) {
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+
+			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		case <-ticker.C:
+			_, _ = w.WriteString(": keep-alive\n\n")
+		}
+
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+func TestStreamDashboardEvents(t *testing.T) {
+	var buffer bytes.Buffer
+
+	events := make(chan services.DashboardEvent, 1)
+	events <- services.DashboardEvent{
+		DashboardID: 1,
+		Type:        services.DashboardEventReady,
+		Time:        time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	close(events)
+	streamDashboardEvents(bufio.NewWriter(&buffer), events, time.Hour)
+
+	assert.Equal(t, buffer.String(),
+		"event: ready\ndata: {\"dashboardId\":1,\"type\":\"ready\",\"time\":\"2023-07-01T12:00:00Z\"}\n\n")
+}
+
+func TestStreamDashboardEventsKeepsAlive(t *testing.T) {
+	var buffer bytes.Buffer
+
+	events := make(chan services.DashboardEvent)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		streamDashboardEvents(bufio.NewWriter(&buffer), events, time.Millisecond)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(events)
+	<-done
+
+	assert.Equal(t, bytes.HasPrefix(buffer.Bytes(), []byte(": keep-alive\n\n")), true)
+}
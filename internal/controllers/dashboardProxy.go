@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+	"github.com/valyala/fasthttp"
+)
+
+// dashboardProxyTouchInterval is how often open connections of the owner count as
+// dashboard activity.
+const dashboardProxyTouchInterval = time.Minute
+
+// newDashboardProxy hands the websocket of the dashboard found by lookup over to
+// the dashboard itself: the upgrade request is replayed to the dashboard and, once
+// the client connection is hijacked, bytes are copied both ways until either side
+// closes. Connections of the owner count as dashboard activity while they are open.
+func newDashboardProxy(
+	logger *core.Logger,
+	dashboardService *services.DashboardService,
+	lookup dashboardLookup,
+	isOwner bool,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !c.Context().Request.Header.ConnectionUpgrade() {
+			return fiber.ErrUpgradeRequired
+		}
+
+		dashboard, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		dashboardConn, err := dashboardService.DialDashboard(c.UserContext(), dashboard)
+		if errors.Is(err, services.ErrDashboardNotRunning) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err != nil {
+			logger.Err(err).Uint64("dashboardId", dashboard.ID).Msg("dashboard dialing")
+
+			return fiber.ErrBadGateway
+		}
+
+		if err := writeProxiedRequest(dashboardConn, c); err != nil {
+			_ = dashboardConn.Close()
+			logger.Err(err).Uint64("dashboardId", dashboard.ID).Msg("dashboard upgrade request")
+
+			return fiber.ErrBadGateway
+		}
+
+		if isOwner {
+			if err := dashboardService.TouchDashboard(c.UserContext(), dashboard); err != nil {
+				logger.Err(err).Msg("dashboard activity update")
+			}
+		}
+
+		// The dashboard answers the upgrade request itself through the hijacked connection.
+		c.Context().HijackSetNoResponse(true)
+		c.Context().Hijack(func(clientConn net.Conn) {
+			done := make(chan struct{})
+			defer close(done)
+
+			if isOwner {
+				go touchWhileConnected(logger, dashboardService, dashboard, done)
+			}
+
+			pipeConns(clientConn, dashboardConn)
+		})
+
+		return nil
+	}
+}
+
+// touchWhileConnected records dashboard activity every dashboardProxyTouchInterval
+// until done is closed, so that dashboards in use through the proxy are not reaped
+// as idle.
+func touchWhileConnected(
+	logger *core.Logger,
+	dashboardService *services.DashboardService,
+	dashboard *models.Dashboard,
+	done <-chan struct{},
+) {
+	ticker := time.NewTicker(dashboardProxyTouchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := dashboardService.TouchDashboard(context.Background(), dashboard); err != nil {
+				logger.Err(err).Uint64("dashboardId", dashboard.ID).Msg("dashboard activity update")
+			}
+		}
+	}
+}
+
+// writeProxiedRequest replays the upgrade request to the dashboard without the
+// credentials of the user, which are meant for the API only.
+func writeProxiedRequest(conn net.Conn, c *fiber.Ctx) error {
+	request := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(request)
+
+	c.Request().CopyTo(request)
+	request.SetRequestURI("/")
+	request.Header.Del(fiber.HeaderAuthorization)
+	request.Header.Del(fiber.HeaderCookie)
+
+	writer := bufio.NewWriter(conn)
+	if err := request.Write(writer); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+// pipeConns copies data between the connections until one of them is closed.
+func pipeConns(clientConn, dashboardConn net.Conn) {
+	var once sync.Once
+
+	closeBoth := func() {
+		_ = clientConn.Close()
+		_ = dashboardConn.Close()
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		_, _ = io.Copy(dashboardConn, clientConn)
+
+		once.Do(closeBoth)
+	}()
+
+	_, _ = io.Copy(clientConn, dashboardConn)
+
+	once.Do(closeBoth)
+	wg.Wait()
+}
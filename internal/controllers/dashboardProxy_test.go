@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+// proxyTestBackend points every dashboard at the same address and fails to start
+// them with startErr.
+type proxyTestBackend struct {
+	address  string
+	startErr error
+}
+
+func (b *proxyTestBackend) Start(context.Context, *models.Dashboard, *models.DashboardTemplate) error {
+	return b.startErr
+}
+
+func (b *proxyTestBackend) UpdatePassword(context.Context, *models.Dashboard) error {
+	return nil
+}
+
+func (b *proxyTestBackend) Stop(context.Context, uint64) (*services.DashboardStopResult, error) {
+	return &services.DashboardStopResult{}, nil
+}
+
+func (b *proxyTestBackend) Status(context.Context, uint64) (*services.DashboardStatus, error) {
+	return &services.DashboardStatus{}, nil
+}
+
+func (b *proxyTestBackend) List(context.Context) ([]services.DashboardInstance, error) {
+	return []services.DashboardInstance{}, nil
+}
+
+func (b *proxyTestBackend) Address(uint64) string {
+	return b.address
+}
+
+type dashboardControllerConstructor func(
+	*core.Database,
+	*core.Logger,
+	*services.UserService,
+	*services.DashboardService,
+	*core.Config,
+) *fiber.App
+
+func setupDashboardProxyComponents(
+	address string,
+	newController dashboardControllerConstructor,
+) (sqlmock.Sqlmock, string, *fiber.App) {
+	db, mock := core.NewMockDatabase()
+	config := core.NewConfig()
+	log := core.NewLogger(config)
+	userService := services.NewUserService(db, log, config)
+	dashboardService := services.NewDashboardServiceWithBackend(db, config, &proxyTestBackend{address: address})
+	controller := newController(db, log, userService, dashboardService, config)
+	token, _ := userService.CreateToken(&models.User{ID: 1})
+
+	return mock, token, controller
+}
+
+// serveFakeDashboard accepts one websocket, reports its upgrade request and echoes
+// everything sent afterwards.
+func serveFakeDashboard(t *testing.T) (string, <-chan *http.Request) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+
+	requests := make(chan *http.Request, 1)
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		request, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+
+		requests <- request
+
+		_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+		_, _ = io.Copy(conn, reader)
+	}()
+
+	return listener.Addr().String(), requests
+}
+
+func testDashboardProxy(t *testing.T, newController dashboardControllerConstructor, path string) {
+	t.Helper()
+
+	address, requests := serveFakeDashboard(t)
+	mock, token, controller := setupDashboardProxyComponents(address, newController)
+
+	mock.ExpectQuery("SELECT \"u\".\"is_active\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_active"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM \"users\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(1, 1, models.DashboardRunning))
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+
+	go func() { _ = controller.Listener(listener) }()
+	defer func() { _ = controller.Shutdown() }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.Equal(t, err, nil)
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(
+		conn,
+		"GET %s?token=%s HTTP/1.1\r\nHost: api\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n",
+		path,
+		token,
+	)
+	assert.Equal(t, err, nil)
+
+	reader := bufio.NewReader(conn)
+
+	response, err := http.ReadResponse(reader, nil)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, response.StatusCode, fiber.StatusSwitchingProtocols)
+
+	request := <-requests
+	assert.Equal(t, request.URL.String(), "/")
+	assert.Equal(t, request.Header.Get("Upgrade"), "websocket")
+
+	_, _ = conn.Write([]byte("ping"))
+	echo := make([]byte, 4)
+	_, err = io.ReadFull(reader, echo)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(echo), "ping")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestDashboardProxy(t *testing.T) {
+	testDashboardProxy(t, NewDashboardController, "/1/ws")
+}
+
+func TestCurrentDashboardProxy(t *testing.T) {
+	testDashboardProxy(t, NewCurrentDashboardController, "/ws")
+}
+
+func TestDashboardProxyRequiresUpgrade(t *testing.T) {
+	mock, token, controller := setupDashboardProxyComponents("", NewDashboardController)
+
+	mock.ExpectQuery("SELECT \"u\".\"is_active\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_active"}).AddRow(true))
+
+	response, _ := controller.Test(httptest.NewRequest("GET", "/1/ws?token="+token, nil), 1)
+	assert.Equal(t, response.StatusCode, fiber.StatusUpgradeRequired)
+}
+
+func TestDashboardProxyRequiresToken(t *testing.T) {
+	_, _, controller := setupDashboardProxyComponents("", NewDashboardController)
+
+	response, _ := controller.Test(httptest.NewRequest("GET", "/1/ws", nil), 1)
+	assert.Equal(t, response.StatusCode, fiber.StatusBadRequest)
+
+	response, _ = controller.Test(httptest.NewRequest("GET", "/?token=invalid", nil), 1)
+	assert.Equal(t, response.StatusCode, fiber.StatusBadRequest)
+}
+
+func TestCurrentDashboardNotFound(t *testing.T) {
+	mock, token, controller := setupDashboardProxyComponents("", NewCurrentDashboardController)
+
+	mock.ExpectQuery("SELECT \"u\".\"is_active\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_active"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM \"users\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\" (.+) ORDER BY \"id\" DESC LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	request := httptest.NewRequest("GET", "/status", nil)
+	request.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+
+	response, _ := controller.Test(request, 1)
+	assert.Equal(t, response.StatusCode, fiber.StatusNotFound)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
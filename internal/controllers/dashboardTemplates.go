@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/middleware"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+var errReservedTemplateEnv = errors.New("PASSWORD environment variable is reserved")
+
+type dashboardTemplatesController struct {
+	db     *core.Database
+	logger *core.Logger
+}
+
+func validateDashboardTemplate(template *models.DashboardTemplate) error {
+	if err := validator.New().Struct(template); err != nil {
+		return err
+	}
+
+	if _, ok := template.Env["PASSWORD"]; ok {
+		return errReservedTemplateEnv
+	}
+
+	_, err := services.DashboardResources{
+		CPURequest:    template.CPURequest,
+		CPULimit:      template.CPULimit,
+		MemoryRequest: template.MemoryRequest,
+		MemoryLimit:   template.MemoryLimit,
+	}.ResourceRequirements()
+
+	return err
+}
+
+func (d *dashboardTemplatesController) listTemplates() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var templates []*models.DashboardTemplate
+
+		err := d.db.NewSelect().Model(&templates).Order("id").Scan(c.UserContext())
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			d.logger.Err(err).Msg("list dashboard templates")
+
+			return fiber.ErrInternalServerError
+		}
+
+		if templates == nil {
+			templates = []*models.DashboardTemplate{}
+		}
+
+		return c.JSON(templates)
+	}
+}
+
+func (d *dashboardTemplatesController) createTemplate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		template := new(models.DashboardTemplate)
+		if err := c.BodyParser(template); err != nil {
+			return err
+		}
+
+		if err := validateDashboardTemplate(template); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		exists, err := d.db.NewSelect().
+			Model(new(models.DashboardTemplate)).
+			Where("name = ?", template.Name).
+			Exists(c.UserContext())
+		if err != nil {
+			d.logger.Err(err).Msg("existent dashboard template selecting")
+
+			return fiber.ErrInternalServerError
+		}
+
+		if exists {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "dashboard template with such name already exists",
+			})
+		}
+
+		_, err = d.db.NewInsert().
+			Model(template).
+			Returning("id").
+			Exec(c.UserContext(), &template.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			d.logger.Err(err).Msg("dashboard template insert")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(template)
+	}
+}
+
+func (d *dashboardTemplatesController) getTemplate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		template := new(models.DashboardTemplate)
+
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+
+		template.ID = uint64(id)
+
+		err = d.db.NewSelect().Model(template).WherePK().Scan(c.UserContext())
+		if errors.Is(err, sql.ErrNoRows) {
+			return fiber.ErrNotFound
+		}
+
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard template selecting")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(template)
+	}
+}
+
+func (d *dashboardTemplatesController) updateTemplate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		template := new(models.DashboardTemplate)
+
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+
+		if err := c.BodyParser(template); err != nil {
+			return err
+		}
+
+		template.ID = uint64(id)
+
+		if err := validateDashboardTemplate(template); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		result, err := d.db.NewUpdate().Model(template).WherePK().Exec(c.UserContext())
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard template update")
+
+			return fiber.ErrInternalServerError
+		}
+
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fiber.ErrNotFound
+		}
+
+		return c.JSON(template)
+	}
+}
+
+func (d *dashboardTemplatesController) deleteTemplate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		template := new(models.DashboardTemplate)
+
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+
+		template.ID = uint64(id)
+
+		_, err = d.db.NewDelete().Model(template).WherePK().Exec(c.UserContext())
+		if err != nil {
+			d.logger.Err(err).Msg("dashboard template delete")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(fiber.Map{
+			"message": "dashboard template deleted successfully",
+		})
+	}
+}
+
+// NewDashboardTemplatesController lets every active user read the dashboard
+// templates, while only super users may change them.
+func NewDashboardTemplatesController(
+	db *core.Database,
+	conf *core.Config,
+	logger *core.Logger,
+) *fiber.App {
+	controller := dashboardTemplatesController{db, logger}
+	isSuperUser := middleware.NewIsSuperUser(db, logger)
+
+	app := fiber.New()
+
+	app.Use(middleware.NewRequireAuth(conf))
+	app.Use(middleware.NewIsActive(db, logger))
+
+	app.Get("/", controller.listTemplates())
+	app.Post("/", isSuperUser, controller.createTemplate())
+	app.Get("/:id", controller.getTemplate())
+	app.Put("/:id", isSuperUser, controller.updateTemplate())
+	app.Delete("/:id", isSuperUser, controller.deleteTemplate())
+
+	return app
+}
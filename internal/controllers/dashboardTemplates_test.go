@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+func setupDashboardTemplatesComponents() (
+	sqlmock.Sqlmock,
+	*services.UserService,
+	*fiber.App,
+) {
+	db, mock := core.NewMockDatabase()
+	config := core.NewConfig()
+	log := core.NewLogger(config)
+	userService := services.NewUserService(db, log, config)
+	controller := NewDashboardTemplatesController(db, config, log)
+
+	return mock, userService, controller
+}
+
+func createDashboardTemplate(
+	mock sqlmock.Sqlmock,
+	userService *services.UserService,
+	controller *fiber.App,
+	isSuperUser bool,
+	body string,
+) int {
+	mock.ExpectQuery("SELECT \"u\".\"is_active\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_active"}).AddRow(true))
+	mock.ExpectQuery("SELECT \"u\".\"is_super_user\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_super_user"}).AddRow(isSuperUser))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	token, _ := userService.CreateToken(&models.User{})
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	response, _ := controller.Test(req, 1)
+
+	return response.StatusCode
+}
+
+func TestCreateDashboardTemplateRequiresSuperUser(t *testing.T) {
+	mock, userService, controller := setupDashboardTemplatesComponents()
+
+	status := createDashboardTemplate(mock, userService, controller, false, `{"name": "python", "image": "python"}`)
+	assert.Equal(t, status, fiber.StatusForbidden)
+}
+
+func TestCreateDashboardTemplateValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing image", `{"name": "python"}`},
+		{"reserved env", `{"name": "python", "image": "python", "env": {"PASSWORD": "secret"}}`},
+		{"bad resources", `{"name": "python", "image": "python", "cpuLimit": "lots"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock, userService, controller := setupDashboardTemplatesComponents()
+
+			status := createDashboardTemplate(mock, userService, controller, true, test.body)
+			assert.Equal(t, status, fiber.StatusBadRequest)
+		})
+	}
+}
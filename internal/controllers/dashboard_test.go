@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+func TestCreateDashboardWhileHomeVolumeIsWiped(t *testing.T) {
+	db, mock := core.NewMockDatabase()
+	config := core.NewConfig()
+	log := core.NewLogger(config)
+	userService := services.NewUserService(db, log, config)
+	backend := &proxyTestBackend{startErr: services.ErrHomeVolumeTerminating}
+	dashboardService := services.NewDashboardServiceWithBackend(db, config, backend)
+	controller := NewDashboardController(db, log, userService, dashboardService, config)
+	token, _ := userService.CreateToken(&models.User{ID: 1})
+
+	mock.ExpectQuery("SELECT \"u\".\"is_active\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_active"}).AddRow(true))
+	mock.ExpectQuery("SELECT (.+) FROM \"users\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("INSERT INTO \"dashboards\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("DELETE FROM \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	request := httptest.NewRequest("POST", "/", nil)
+	request.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+
+	response, _ := controller.Test(request, 1)
+	assert.Equal(t, response.StatusCode, fiber.StatusServiceUnavailable)
+	assert.Equal(t, response.Header.Get(fiber.HeaderRetryAfter), dashboardHomeVolumeRetryAfter)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
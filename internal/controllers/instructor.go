@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/middleware"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+type instructorController struct {
+	db               *core.Database
+	logger           *core.Logger
+	userService      *services.UserService
+	dashboardService *services.DashboardService
+}
+
+// studentDashboard returns the dashboard the route points at, whoever owns it.
+func (i *instructorController) studentDashboard(c *fiber.Ctx) (*models.Dashboard, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, fiber.ErrNotFound
+	}
+
+	dashboard, err := i.dashboardService.GetStudentDashboard(c.UserContext(), uint64(id))
+	if errors.Is(err, services.ErrDashboardNotFound) {
+		return nil, fiber.ErrNotFound
+	}
+
+	if err != nil {
+		i.logger.Err(err).Msg("student dashboard selecting")
+
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return dashboard, nil
+}
+
+func (i *instructorController) listStudentDashboards() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+
+		dashboards, err := i.dashboardService.ListDashboards(c.UserContext(), &models.User{ID: uint64(id)})
+		if err != nil {
+			i.logger.Err(err).Msg("list student dashboards")
+
+			return fiber.ErrInternalServerError
+		}
+
+		// Instructors never get the full access password of a student.
+		for _, dashboard := range dashboards {
+			dashboard.Password = ""
+		}
+
+		return c.JSON(dashboards)
+	}
+}
+
+func (i *instructorController) grantViewOnlyAccess() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, _ := c.Locals("user").(*jwt.Token)
+
+		instructor, err := i.userService.GetUserByToken(c.UserContext(), token)
+		if err != nil {
+			i.logger.Err(err).Msg("instructor selecting")
+
+			return fiber.ErrInternalServerError
+		}
+
+		dashboard, err := i.studentDashboard(c)
+		if err != nil {
+			return err
+		}
+
+		err = i.dashboardService.GrantViewOnlyAccess(c.UserContext(), instructor, dashboard)
+		if errors.Is(err, services.ErrDashboardNotRunning) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		if err != nil {
+			i.logger.Err(err).Msg("dashboard view-only access granting")
+
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(fiber.Map{
+			"id":               dashboard.ID,
+			"viewOnlyPassword": dashboard.ViewOnlyPassword,
+		})
+	}
+}
+
+func NewInstructorController(
+	db *core.Database,
+	conf *core.Config,
+	logger *core.Logger,
+	userService *services.UserService,
+	dashboardService *services.DashboardService,
+) *fiber.App {
+	controller := instructorController{db, logger, userService, dashboardService}
+
+	app := fiber.New()
+
+	// The websocket carries no more than the view-only password lets the instructor see.
+	app.Get(
+		"/dashboards/:id/ws",
+		middleware.NewRequireQueryAuth(conf),
+		middleware.NewIsActive(db, logger),
+		middleware.NewIsInstructor(db, logger),
+		newDashboardProxy(logger, dashboardService, controller.studentDashboard, false),
+	)
+
+	app.Use(middleware.NewRequireAuth(conf))
+	app.Use(middleware.NewIsActive(db, logger))
+	app.Use(middleware.NewIsInstructor(db, logger))
+
+	app.Get("/users/:id/dashboards", controller.listStudentDashboards())
+	app.Post("/dashboards/:id/view-only", controller.grantViewOnlyAccess())
+
+	return app
+}
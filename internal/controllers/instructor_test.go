@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/tutorin-tech/tit-backend/internal/services"
+)
+
+func setupInstructorComponents() (sqlmock.Sqlmock, string, *fiber.App) {
+	db, mock := core.NewMockDatabase()
+	config := core.NewConfig()
+	log := core.NewLogger(config)
+	userService := services.NewUserService(db, log, config)
+	dashboardService := services.NewDashboardServiceWithBackend(db, config, &proxyTestBackend{})
+	controller := NewInstructorController(db, config, log, userService, dashboardService)
+	token, _ := userService.CreateToken(&models.User{ID: 2})
+
+	return mock, token, controller
+}
+
+func instructorRequest(controller *fiber.App, token string, method string, target string) *http.Response {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	response, _ := controller.Test(req, 1)
+
+	return response
+}
+
+func expectInstructor(mock sqlmock.Sqlmock, isInstructor bool) {
+	mock.ExpectQuery("SELECT \"u\".\"is_active\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_active"}).AddRow(true))
+	mock.ExpectQuery("SELECT \"u\".\"is_instructor\", \"u\".\"is_super_user\"").
+		WillReturnRows(sqlmock.NewRows([]string{"is_instructor", "is_super_user"}).AddRow(isInstructor, false))
+}
+
+func TestInstructorRoutesRequireInstructor(t *testing.T) {
+	mock, token, controller := setupInstructorComponents()
+
+	expectInstructor(mock, false)
+
+	response := instructorRequest(controller, token, "GET", "/users/1/dashboards")
+	assert.Equal(t, response.StatusCode, fiber.StatusForbidden)
+}
+
+func TestInstructorListsStudentDashboardsWithoutPasswords(t *testing.T) {
+	mock, token, controller := setupInstructorComponents()
+
+	expectInstructor(mock, true)
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\" AS \"d\" WHERE \\(user_id = 1\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "password", "view_only_password"}).
+			AddRow(1, 1, models.DashboardRunning, "secret", "watch"))
+
+	response := instructorRequest(controller, token, "GET", "/users/1/dashboards")
+	assert.Equal(t, response.StatusCode, fiber.StatusOK)
+
+	var dashboards []map[string]interface{}
+
+	_ = json.NewDecoder(response.Body).Decode(&dashboards)
+	assert.Equal(t, len(dashboards), 1)
+	assert.Equal(t, dashboards[0]["password"], nil)
+	assert.Equal(t, dashboards[0]["viewOnlyPassword"], nil)
+}
+
+func TestInstructorGrantsViewOnlyAccess(t *testing.T) {
+	mock, token, controller := setupInstructorComponents()
+
+	expectInstructor(mock, true)
+	mock.ExpectQuery("SELECT (.+) FROM \"users\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(1, 1, models.DashboardRunning))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO \"dashboard_access_logs\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	response := instructorRequest(controller, token, "POST", "/dashboards/1/view-only")
+	assert.Equal(t, response.StatusCode, fiber.StatusOK)
+
+	var body map[string]interface{}
+
+	_ = json.NewDecoder(response.Body).Decode(&body)
+	assert.NotEqual(t, body["viewOnlyPassword"], "")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
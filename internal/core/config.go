@@ -11,25 +11,77 @@ const (
 	defaultPort           = 3000
 	defaultPgPort         = 5432
 	defaultJWTExpireHours = 24 * 3
+
+	defaultDashboardReaperIntervalSeconds = 60
+	defaultDashboardReadyTimeoutSeconds   = 120
+	defaultDashboardDockerPortBase        = 20000
+	defaultDashboardReconcileSeconds      = 300
+	defaultDashboardWarmPoolSeconds       = 15
+	defaultDashboardProbePeriodSeconds    = 5
+	defaultDashboardProbeTimeoutSeconds   = 1
+	defaultDashboardReadinessThreshold    = 3
+	defaultDashboardLivenessDelaySeconds  = 15
+	defaultDashboardLivenessThreshold     = 6
 )
 
 type Config struct {
-	Debug                         bool
-	Port                          int
-	PgHost                        string
-	PgPort                        int
-	PgName                        string
-	PgUser                        string
-	PgPassword                    string
-	SecretKey                     string
-	JWTExpireHours                int
-	KubernetesUseInClusterConfig  bool
-	KubernetesConfigPath          string
-	KubernetesDashboardNamespace  string
-	DashboardImage                string
-	DashboardIngressDomain        string
-	DashboardIngressTLSSecretName string
-	DashboardTLSClusterIssuer     string
+	Debug                               bool
+	Port                                int
+	PgHost                              string
+	PgPort                              int
+	PgName                              string
+	PgUser                              string
+	PgPassword                          string
+	SecretKey                           string
+	JWTExpireHours                      int
+	KubernetesUseInClusterConfig        bool
+	KubernetesConfigPath                string
+	KubernetesDashboardNamespace        string
+	DashboardImage                      string
+	DashboardIngressDomain              string
+	DashboardIngressTLSSecretName       string
+	DashboardTLSClusterIssuer           string
+	DashboardIdleTimeoutMinutes         int
+	DashboardReaperIntervalSeconds      int
+	DashboardReadyTimeoutSeconds        int
+	DashboardBackend                    string
+	DashboardDockerBinary               string
+	DashboardDockerPortBase             int
+	DashboardIngressControllerNamespace string
+	DashboardIngressControllerPodLabels map[string]string
+	DashboardEgressAllowList            []string
+	DashboardCPURequest                 string
+	DashboardCPULimit                   string
+	DashboardMemoryRequest              string
+	DashboardMemoryLimit                string
+	DashboardNodeSelector               map[string]string
+	DashboardTolerations                []string
+	DashboardPriorityClassName          string
+	DashboardReconcileIntervalSeconds   int
+	DashboardWarmPoolSize               int
+	DashboardWarmPoolIntervalSeconds    int
+	DashboardHomeVolumeSize             string
+	DashboardHomeVolumeStorageClass     string
+	DashboardHomeMountPath              string
+	DashboardLimitPerUser               int
+	DashboardIngressStrategy            string
+	DashboardIngressClassName           string
+	DashboardIngressSubdomains          bool
+	DashboardGatewayName                string
+	DashboardGatewayNamespace           string
+	DashboardProxyNamespace             string
+	DashboardProxyPodLabels             map[string]string
+	DashboardMaxRunning                 int
+	DashboardMaxRunningPerRole          map[string]int
+	DashboardMonthlyQuotaHours          int
+	DashboardSecurityProfile            string
+	DashboardRunAsUser                  int
+	DashboardRuntimeClassName           string
+	DashboardProbePeriodSeconds         int
+	DashboardProbeTimeoutSeconds        int
+	DashboardReadinessFailureThreshold  int
+	DashboardLivenessDelaySeconds       int
+	DashboardLivenessFailureThreshold   int
 }
 
 func NewConfig() *Config {
@@ -52,5 +104,68 @@ func NewConfig() *Config {
 		DashboardIngressDomain:        utils.GetEnv("DASHBOARD_INGRESS_DOMAIN"),
 		DashboardIngressTLSSecretName: utils.GetEnvOrDefault("DASHBOARD_INGRESS_TLS_SECRET_NAME", ""),
 		DashboardTLSClusterIssuer:     utils.GetEnvOrDefault("DASHBOARD_TLS_CLUSTER_ISSUER", ""),
+		DashboardIdleTimeoutMinutes: utils.GetEnvIntOrDefault(
+			"DASHBOARD_IDLE_TIMEOUT_MINUTES", 0,
+		),
+		DashboardReaperIntervalSeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_REAPER_INTERVAL_SECONDS", defaultDashboardReaperIntervalSeconds,
+		),
+		DashboardReadyTimeoutSeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_READY_TIMEOUT_SECONDS", defaultDashboardReadyTimeoutSeconds,
+		),
+		DashboardBackend:        utils.GetEnvOrDefault("DASHBOARD_BACKEND", "kubernetes"),
+		DashboardDockerBinary:   utils.GetEnvOrDefault("DASHBOARD_DOCKER_BINARY", "docker"),
+		DashboardDockerPortBase: utils.GetEnvIntOrDefault("DASHBOARD_DOCKER_PORT_BASE", defaultDashboardDockerPortBase),
+		DashboardIngressControllerNamespace: utils.GetEnvOrDefault(
+			"DASHBOARD_INGRESS_CONTROLLER_NAMESPACE", "kube-system",
+		),
+		DashboardIngressControllerPodLabels: utils.GetEnvMapOrDefault("DASHBOARD_INGRESS_CONTROLLER_POD_LABELS", nil),
+		DashboardEgressAllowList:            utils.GetEnvListOrDefault("DASHBOARD_EGRESS_ALLOW_LIST", nil),
+		DashboardCPURequest:                 utils.GetEnvOrDefault("DASHBOARD_CPU_REQUEST", ""),
+		DashboardCPULimit:                   utils.GetEnvOrDefault("DASHBOARD_CPU_LIMIT", ""),
+		DashboardMemoryRequest:              utils.GetEnvOrDefault("DASHBOARD_MEMORY_REQUEST", ""),
+		DashboardMemoryLimit:                utils.GetEnvOrDefault("DASHBOARD_MEMORY_LIMIT", ""),
+		DashboardNodeSelector:               utils.GetEnvMapOrDefault("DASHBOARD_NODE_SELECTOR", nil),
+		DashboardTolerations:                utils.GetEnvListOrDefault("DASHBOARD_TOLERATIONS", nil),
+		DashboardPriorityClassName:          utils.GetEnvOrDefault("DASHBOARD_PRIORITY_CLASS_NAME", ""),
+		DashboardReconcileIntervalSeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_RECONCILE_INTERVAL_SECONDS", defaultDashboardReconcileSeconds,
+		),
+		DashboardWarmPoolSize: utils.GetEnvIntOrDefault("DASHBOARD_WARM_POOL_SIZE", 0),
+		DashboardWarmPoolIntervalSeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_WARM_POOL_INTERVAL_SECONDS", defaultDashboardWarmPoolSeconds,
+		),
+		DashboardHomeVolumeSize:         utils.GetEnvOrDefault("DASHBOARD_HOME_VOLUME_SIZE", ""),
+		DashboardHomeVolumeStorageClass: utils.GetEnvOrDefault("DASHBOARD_HOME_VOLUME_STORAGE_CLASS", ""),
+		DashboardHomeMountPath:          utils.GetEnvOrDefault("DASHBOARD_HOME_MOUNT_PATH", "/home/dashboard"),
+		DashboardLimitPerUser:           utils.GetEnvIntOrDefault("DASHBOARD_LIMIT_PER_USER", 1),
+		DashboardIngressStrategy:        utils.GetEnvOrDefault("DASHBOARD_INGRESS_STRATEGY", "traefik"),
+		DashboardIngressClassName:       utils.GetEnvOrDefault("DASHBOARD_INGRESS_CLASS_NAME", ""),
+		DashboardIngressSubdomains:      utils.GetEnvOrDefault("DASHBOARD_INGRESS_SUBDOMAINS", "false") == "true",
+		DashboardGatewayName:            utils.GetEnvOrDefault("DASHBOARD_GATEWAY_NAME", ""),
+		DashboardGatewayNamespace:       utils.GetEnvOrDefault("DASHBOARD_GATEWAY_NAMESPACE", ""),
+		DashboardProxyNamespace:         utils.GetEnvOrDefault("DASHBOARD_PROXY_NAMESPACE", ""),
+		DashboardProxyPodLabels:         utils.GetEnvMapOrDefault("DASHBOARD_PROXY_POD_LABELS", nil),
+		DashboardMaxRunning:             utils.GetEnvIntOrDefault("DASHBOARD_MAX_RUNNING", 0),
+		DashboardMaxRunningPerRole:      utils.GetEnvIntMapOrDefault("DASHBOARD_MAX_RUNNING_PER_ROLE", nil),
+		DashboardMonthlyQuotaHours:      utils.GetEnvIntOrDefault("DASHBOARD_MONTHLY_QUOTA_HOURS", 0),
+		DashboardSecurityProfile:        utils.GetEnvOrDefault("DASHBOARD_SECURITY_PROFILE", "restricted"),
+		DashboardRunAsUser:              utils.GetEnvIntOrDefault("DASHBOARD_RUN_AS_USER", 0),
+		DashboardRuntimeClassName:       utils.GetEnvOrDefault("DASHBOARD_RUNTIME_CLASS_NAME", ""),
+		DashboardProbePeriodSeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_PROBE_PERIOD_SECONDS", defaultDashboardProbePeriodSeconds,
+		),
+		DashboardProbeTimeoutSeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_PROBE_TIMEOUT_SECONDS", defaultDashboardProbeTimeoutSeconds,
+		),
+		DashboardReadinessFailureThreshold: utils.GetEnvIntOrDefault(
+			"DASHBOARD_READINESS_FAILURE_THRESHOLD", defaultDashboardReadinessThreshold,
+		),
+		DashboardLivenessDelaySeconds: utils.GetEnvIntOrDefault(
+			"DASHBOARD_LIVENESS_INITIAL_DELAY_SECONDS", defaultDashboardLivenessDelaySeconds,
+		),
+		DashboardLivenessFailureThreshold: utils.GetEnvIntOrDefault(
+			"DASHBOARD_LIVENESS_FAILURE_THRESHOLD", defaultDashboardLivenessThreshold,
+		),
 	}
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+// NewIsInstructor lets instructors and super users through.
+func NewIsInstructor(db *core.Database, logger *core.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, _ := c.Locals("user").(*jwt.Token)
+		claims, _ := token.Claims.(jwt.MapClaims)
+		userID, _ := claims["userId"].(float64)
+
+		var isInstructor, isSuperUser bool
+
+		err := db.NewSelect().
+			Model(new(models.User)).
+			Where("id = ?", userID).
+			Column("is_instructor", "is_super_user").
+			Scan(c.UserContext(), &isInstructor, &isSuperUser)
+		if err != nil {
+			logger.Err(err).Msg("is instructor middleware")
+
+			return fiber.ErrInternalServerError
+		}
+
+		if !isInstructor && !isSuperUser {
+			return fiber.ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
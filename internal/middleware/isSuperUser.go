@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+func NewIsSuperUser(db *core.Database, logger *core.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, _ := c.Locals("user").(*jwt.Token)
+		claims, _ := token.Claims.(jwt.MapClaims)
+		userID, _ := claims["userId"].(float64)
+
+		var isSuperUser bool
+
+		err := db.NewSelect().
+			Model(new(models.User)).
+			Where("id = ?", userID).
+			Column("is_super_user").
+			Scan(c.UserContext(), &isSuperUser)
+		if err != nil {
+			logger.Err(err).Msg("is super user middleware")
+
+			return fiber.ErrInternalServerError
+		}
+
+		if !isSuperUser {
+			return fiber.ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
@@ -8,10 +8,21 @@ import (
 )
 
 func NewRequireAuth(conf *core.Config) fiber.Handler {
+	return newRequireAuth(conf, "header:Authorization")
+}
+
+// NewRequireQueryAuth also accepts the JWT in the "token" query parameter,
+// since browsers cannot set headers on websocket and EventSource requests.
+func NewRequireQueryAuth(conf *core.Config) fiber.Handler {
+	return newRequireAuth(conf, "header:Authorization,query:token")
+}
+
+func newRequireAuth(conf *core.Config, tokenLookup string) fiber.Handler {
 	return jwtware.New(jwtware.Config{
 		ContextKey:    "user",
 		SigningMethod: jwt.SigningMethodHS256.Name,
 		SigningKey:    []byte(conf.SecretKey),
+		TokenLookup:   tokenLookup,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			if err.Error() == "Missing or malformed JWT" {
 				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
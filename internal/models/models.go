@@ -1,25 +1,100 @@
 package models
 
-import "github.com/uptrace/bun"
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
 
 type User struct {
 	bun.BaseModel `bun:"table:users,alias:u"`
 
-	ID                uint64 `bun:"id,pk,autoincrement" json:"-"`
-	Email             string `bun:"email,unique,notnull" json:"email" validate:"required,email"`
-	PasswordHash      string `bun:"password_hash,notnull" json:"-"`
-	IsSuperUser       bool   `bun:"is_super_user,notnull" json:"isSuperUser"`
-	IsActive          bool   `bun:"is_active,notnull"`
-	DashboardPassword string `bun:"dashboard_password" json:"dashboardPassword"`
+	ID           uint64 `bun:"id,pk,autoincrement" json:"-"`
+	Email        string `bun:"email,unique,notnull" json:"email" validate:"required,email"`
+	PasswordHash string `bun:"password_hash,notnull" json:"-"`
+	IsSuperUser  bool   `bun:"is_super_user,notnull" json:"isSuperUser"`
+	IsInstructor bool   `bun:"is_instructor,notnull" json:"isInstructor"`
+	IsActive     bool   `bun:"is_active,notnull"`
+	// DashboardQuotaHours overrides the configured monthly dashboard quota of the user.
+	DashboardQuotaHours *int `bun:"dashboard_quota_hours" json:"dashboardQuotaHours,omitempty"`
 
 	Password string `bun:"-" json:"password,omitempty" validate:"required,min=8,max=256"`
 	Token    string `bun:"-" json:"token,omitempty"`
 }
 
+// Statuses of a Dashboard record. Stopped dashboards are kept for history.
+const (
+	DashboardStarting = "starting"
+	DashboardRunning  = "running"
+	DashboardStopped  = "stopped"
+)
+
+type Dashboard struct {
+	bun.BaseModel `bun:"table:dashboards,alias:d"`
+
+	ID         uint64  `bun:"id,pk,autoincrement" json:"id"`
+	UserID     uint64  `bun:"user_id,notnull" json:"userId"`
+	TemplateID *uint64 `bun:"template_id" json:"templateId"`
+	Status     string  `bun:"status,notnull" json:"status"`
+	Password   string  `bun:"password,notnull" json:"password,omitempty"`
+	// ViewOnlyPassword is handed out to instructors only, never to the owner.
+	ViewOnlyPassword string     `bun:"view_only_password,notnull" json:"-"`
+	CreatedAt        time.Time  `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+	LastActivityAt   time.Time  `bun:"last_activity_at,notnull,default:current_timestamp" json:"lastActivityAt"`
+	StoppedAt        *time.Time `bun:"stopped_at" json:"stoppedAt,omitempty"`
+}
+
+// Kinds of access an instructor is granted to the dashboard of a student.
+const (
+	DashboardAccessViewOnly = "view-only"
+)
+
+// DashboardAccessLog records every time an instructor obtained access to the
+// dashboard of a student.
+type DashboardAccessLog struct {
+	bun.BaseModel `bun:"table:dashboard_access_logs,alias:dal"`
+
+	ID           uint64    `bun:"id,pk,autoincrement" json:"id"`
+	DashboardID  uint64    `bun:"dashboard_id,notnull" json:"dashboardId"`
+	StudentID    uint64    `bun:"student_id,notnull" json:"studentId"`
+	InstructorID uint64    `bun:"instructor_id,notnull" json:"instructorId"`
+	Access       string    `bun:"access,notnull" json:"access"`
+	CreatedAt    time.Time `bun:"created_at,notnull,default:current_timestamp" json:"createdAt"`
+}
+
+// DashboardUsage is an interval the dashboard ran for, StoppedAt is nil while it runs.
+type DashboardUsage struct {
+	bun.BaseModel `bun:"table:dashboard_usages,alias:du"`
+
+	ID          uint64     `bun:"id,pk,autoincrement" json:"id"`
+	DashboardID uint64     `bun:"dashboard_id,notnull" json:"dashboardId"`
+	UserID      uint64     `bun:"user_id,notnull" json:"userId"`
+	StartedAt   time.Time  `bun:"started_at,notnull,default:current_timestamp" json:"startedAt"`
+	StoppedAt   *time.Time `bun:"stopped_at" json:"stoppedAt,omitempty"`
+}
+
 type Tutorial struct {
 	bun.BaseModel `bun:"table:tutorials,alias:tuts"`
 
 	ID      uint64 `bun:"id,pk,autoincrement" json:"id"`
 	Name    string `bun:"name,unique,notnull" json:"name"`
 	Content string `bun:"content,notnull" json:"content"`
+
+	DashboardTemplateID *uint64 `bun:"dashboard_template_id" json:"dashboardTemplateId"`
+}
+
+// DashboardTemplate describes the environment of the dashboards started for
+// a tutorial. Empty fields fall back to the configured defaults.
+type DashboardTemplate struct {
+	bun.BaseModel `bun:"table:dashboard_templates,alias:dt"`
+
+	ID            uint64            `bun:"id,pk,autoincrement" json:"id"`
+	Name          string            `bun:"name,unique,notnull" json:"name" validate:"required,max=64"`
+	Image         string            `bun:"image,notnull" json:"image" validate:"required,max=256"`
+	Env           map[string]string `bun:"env,type:jsonb" json:"env"`
+	Port          int               `bun:"port,notnull" json:"port" validate:"min=0,max=65535"`
+	CPURequest    string            `bun:"cpu_request,notnull" json:"cpuRequest"`
+	CPULimit      string            `bun:"cpu_limit,notnull" json:"cpuLimit"`
+	MemoryRequest string            `bun:"memory_request,notnull" json:"memoryRequest"`
+	MemoryLimit   string            `bun:"memory_limit,notnull" json:"memoryLimit"`
 }
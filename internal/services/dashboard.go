@@ -2,231 +2,816 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tutorin-tech/tit-backend/internal/core"
 	"github.com/tutorin-tech/tit-backend/internal/models"
-	coreV1 "k8s.io/api/core/v1"
-	networkingV1 "k8s.io/api/networking/v1"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/intstr"
-	"k8s.io/client-go/kubernetes"
-	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	typedNetworkingV1 "k8s.io/client-go/kubernetes/typed/networking/v1"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/utils/pointer"
+	"github.com/uptrace/bun"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
-const dashboardPort = 8888
+const (
+	DashboardBackendKubernetes = "kubernetes"
+	DashboardBackendDocker     = "docker"
+
+	// dashboardPasswordFile is where the dashboard entrypoint keeps the password
+	// file, which x11vnc re-reads on every connection.
+	dashboardPasswordFile = "/tmp/tit-dashboard-password"
+
+	dashboardPollInterval = time.Second
+	dashboardDialTimeout  = 5 * time.Second
+	// dashboardLockClass is the first key of the Postgres advisory locks which
+	// serialize provisioning of the dashboards of a user, whose ID is the second key.
+	dashboardLockClass = 1
+)
+
+var (
+	ErrDashboardReadyTimeout = errors.New("dashboard did not become ready in time")
+	ErrDashboardFailed       = errors.New("dashboard failed to start")
+	ErrDashboardNotRunning   = errors.New("dashboard is not running")
+	ErrDashboardNotFound     = errors.New("dashboard not found")
+	ErrDashboardLimitReached = errors.New("dashboard limit reached")
+	ErrTutorialNotFound      = errors.New("tutorial not found")
+	ErrHomeVolumesDisabled   = errors.New("dashboard home volumes are disabled")
+	ErrHomeVolumeNotFound    = errors.New("dashboard home volume not found")
+	ErrHomeVolumeTerminating = errors.New("dashboard home volume is being wiped")
+
+	errUnknownDashboardBackend = errors.New("unknown dashboard backend")
+)
 
 type DashboardData struct {
 	Password string `json:"password"`
 }
 
+// DashboardStopResult lists kinds of the dashboard resources removed by StopDashboard.
+type DashboardStopResult struct {
+	Deleted []string `json:"deleted"`
+}
+
+type DashboardState string
+
+const (
+	DashboardStateStopped      DashboardState = "stopped"
+	DashboardStateProvisioning DashboardState = "provisioning"
+	DashboardStateReady        DashboardState = "ready"
+	DashboardStateTerminating  DashboardState = "terminating"
+	DashboardStateFailed       DashboardState = "failed"
+)
+
+// DashboardStatus describes the observed state of the dashboard resources.
+// Ready is true only when the dashboard container passes its readiness check.
+// Restarts counts the restarts of the dashboard container, e.g. after it failed
+// its liveness check. TemplateID is zero for dashboards started from the default template.
+type DashboardStatus struct {
+	State      DashboardState `json:"state"`
+	Reason     string         `json:"reason,omitempty"`
+	PodPhase   string         `json:"podPhase,omitempty"`
+	Pod        bool           `json:"pod"`
+	Ready      bool           `json:"ready"`
+	Restarts   int32          `json:"restarts,omitempty"`
+	Service    bool           `json:"service"`
+	Ingress    bool           `json:"ingress"`
+	TemplateID uint64         `json:"templateId,omitempty"`
+}
+
+// DashboardInstance is a dashboard found running by a DashboardBackend, a pod or a
+// container. Pre-started dashboards of the warm pool have zero DashboardID.
+type DashboardInstance struct {
+	Name        string
+	DashboardID uint64
+	Phase       string
+	Node        string
+	CreatedAt   time.Time
+}
+
+// DashboardPod describes a dashboard pod to the administrators. Pods of the warm
+// pool have no dashboard and so no owner yet.
+type DashboardPod struct {
+	Name        string    `json:"name"`
+	DashboardID uint64    `json:"dashboardId,omitempty"`
+	UserID      uint64    `json:"userId,omitempty"`
+	OwnerEmail  string    `json:"ownerEmail,omitempty"`
+	Phase       string    `json:"phase"`
+	Node        string    `json:"node,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Age         string    `json:"age"`
+}
+
+// DashboardStartOptions tweak how CreateDashboard provisions the dashboard.
+type DashboardStartOptions struct {
+	// WaitReady blocks CreateDashboard until the dashboard container is ready.
+	WaitReady bool
+	// TutorialID selects the dashboard template of the tutorial, zero means the default one.
+	TutorialID uint64
+}
+
+// DashboardBackend runs dashboards somewhere: in a Kubernetes cluster, a local
+// container engine, etc. Start is expected to clean up after itself on failure.
+// Dashboards are identified by the ID of their record.
+type DashboardBackend interface {
+	// Start may replace dashboard.Password, e.g. with the one of a pre-started dashboard.
+	Start(ctx context.Context, dashboard *models.Dashboard, template *models.DashboardTemplate) error
+	// UpdatePassword applies dashboard.Password and dashboard.ViewOnlyPassword to the running dashboard.
+	UpdatePassword(ctx context.Context, dashboard *models.Dashboard) error
+	Stop(ctx context.Context, dashboardID uint64) (*DashboardStopResult, error)
+	Status(ctx context.Context, dashboardID uint64) (*DashboardStatus, error)
+	// List returns the running dashboards, oldest first.
+	List(ctx context.Context) ([]DashboardInstance, error)
+	// Address is the host:port the API server reaches the dashboard at when proxying.
+	Address(dashboardID uint64) string
+}
+
+// dashboardReadyWaiter is implemented by backends able to wait for readiness
+// without polling Status.
+type dashboardReadyWaiter interface {
+	WaitReady(ctx context.Context, dashboardID uint64) error
+}
+
 type DashboardService struct {
-	db              *core.Database
-	conf            *core.Config
-	clientSet       *kubernetes.Clientset
-	podsClient      typedCoreV1.PodInterface
-	servicesClient  typedCoreV1.ServiceInterface
-	ingressesClient typedNetworkingV1.IngressInterface
+	db        *core.Database
+	conf      *core.Config
+	backend   DashboardBackend
+	events    *dashboardEventBus
+	admission *dashboardAdmission
 }
 
 func NewDashboardService(db *core.Database, conf *core.Config) (*DashboardService, error) {
 	var (
-		clientConfig *rest.Config
-		err          error
+		backend DashboardBackend
+		err     error
 	)
 
-	if conf.KubernetesUseInClusterConfig {
-		clientConfig, err = rest.InClusterConfig()
-	} else {
-		clientConfig, err = clientcmd.BuildConfigFromFlags("", conf.KubernetesConfigPath)
+	switch conf.DashboardBackend {
+	case DashboardBackendKubernetes:
+		backend, err = NewKubernetesDashboardBackend(conf)
+	case DashboardBackendDocker:
+		backend = NewDockerDashboardBackend(conf)
+	default:
+		err = fmt.Errorf("%w: %s", errUnknownDashboardBackend, conf.DashboardBackend)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	clientSet, err := kubernetes.NewForConfig(clientConfig)
+	return NewDashboardServiceWithBackend(db, conf, backend), nil
+}
+
+func NewDashboardServiceWithBackend(
+	db *core.Database,
+	conf *core.Config,
+	backend DashboardBackend,
+) *DashboardService {
+	dashboardService := &DashboardService{db: db, conf: conf, backend: backend, events: newDashboardEventBus()}
+	dashboardService.admission = newDashboardAdmission(
+		conf.DashboardMaxRunning,
+		conf.DashboardMaxRunningPerRole,
+		dashboardService.countRunningDashboards,
+	)
+
+	return dashboardService
+}
+
+func (d *DashboardService) generateRandomPassword() string {
+	return uuid.New().String()
+}
+
+// dashboardTemplate returns the dashboard template of the tutorial. Tutorials
+// without a template, as well as zero tutorialID, get the default template,
+// which has zero ID and the configured image.
+func (d *DashboardService) dashboardTemplate(
+	ctx context.Context,
+	tutorialID uint64,
+) (*models.DashboardTemplate, error) {
+	template := &models.DashboardTemplate{Image: d.conf.DashboardImage}
+	if tutorialID == 0 {
+		return template, nil
+	}
+
+	tutorial := &models.Tutorial{ID: tutorialID}
+
+	err := d.db.NewSelect().Model(tutorial).WherePK().Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTutorialNotFound
+	}
+
+	if err != nil || tutorial.DashboardTemplateID == nil {
+		return template, err
+	}
+
+	template.ID = *tutorial.DashboardTemplateID
+
+	err = d.db.NewSelect().Model(template).WherePK().Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &models.DashboardTemplate{Image: d.conf.DashboardImage}, nil
+	}
+
+	return template, err
+}
+
+// withDashboardLock runs fn while holding the dashboard advisory lock of the user.
+func (d *DashboardService) withDashboardLock(
+	ctx context.Context,
+	userID uint64,
+	fn func(ctx context.Context) error,
+) error {
+	return d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?, ?)", dashboardLockClass, userID); err != nil {
+			return err
+		}
+
+		return fn(ctx)
+	})
+}
+
+// CreateDashboard starts a new dashboard for the user, from the template of
+// the requested tutorial. Creations for the same user are serialized, so that
+// concurrent requests cannot exceed the configured limit of running dashboards.
+// Users at the limit get their latest dashboard of the same template instead,
+// which makes repeated starts idempotent. Users over their monthly quota get
+// ErrDashboardQuotaExceeded. When the cluster is at capacity the start is queued
+// instead and a DashboardQueuedError is returned until the caller's turn comes.
+func (d *DashboardService) CreateDashboard(
+	ctx context.Context,
+	user *models.User,
+	opts DashboardStartOptions,
+) (*models.Dashboard, error) {
+	template, err := d.dashboardTemplate(ctx, opts.TutorialID)
 	if err != nil {
 		return nil, err
 	}
 
-	podsClient := clientSet.CoreV1().Pods(conf.KubernetesDashboardNamespace)
-	servicesClient := clientSet.CoreV1().Services(conf.KubernetesDashboardNamespace)
-	ingressesClient := clientSet.NetworkingV1().Ingresses(conf.KubernetesDashboardNamespace)
-
-	return &DashboardService{
-		db:              db,
-		conf:            conf,
-		clientSet:       clientSet,
-		podsClient:      podsClient,
-		servicesClient:  servicesClient,
-		ingressesClient: ingressesClient,
-	}, nil
-}
-
-func (d *DashboardService) createPodForUser(user *models.User) *coreV1.Pod {
-	resourceName := fmt.Sprintf("tit-dashboard-%d", user.ID)
-
-	return &coreV1.Pod{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name: resourceName,
-			Labels: map[string]string{
-				"tier": "dashboard",
-				"app":  resourceName,
-			},
-		},
-		Spec: coreV1.PodSpec{
-			TerminationGracePeriodSeconds: pointer.Int64(0),
-			Containers: []coreV1.Container{
-				{
-					Name:  "dashboard",
-					Image: d.conf.DashboardImage,
-					Env: []coreV1.EnvVar{
-						{Name: "PASSWORD", Value: user.DashboardPassword},
-					},
-					Ports: []coreV1.ContainerPort{
-						{
-							ContainerPort: dashboardPort,
-						},
-					},
-				},
-			},
-		},
-	}
-}
-
-func (d *DashboardService) createServiceForUser(user *models.User) *coreV1.Service {
-	resourceName := fmt.Sprintf("tit-dashboard-%d", user.ID)
-
-	return &coreV1.Service{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:      resourceName,
-			Namespace: coreV1.NamespaceDefault,
-			Labels: map[string]string{
-				"app": resourceName,
-			},
-		},
-		Spec: coreV1.ServiceSpec{
-			Ports: []coreV1.ServicePort{
-				{
-					Port: dashboardPort,
-					TargetPort: intstr.IntOrString{
-						IntVal: dashboardPort,
-					},
-				},
-			},
-			Selector: map[string]string{
-				"app": resourceName,
-			},
-		},
-	}
-}
-
-func (d *DashboardService) createIngressForUser(user *models.User) *networkingV1.Ingress {
-	resourceName := fmt.Sprintf("tit-dashboard-%d", user.ID)
-	pathTypePrefix := networkingV1.PathTypePrefix
-
-	return &networkingV1.Ingress{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name: resourceName,
-			Annotations: map[string]string{
-				"traefik.ingress.kubernetes.io/router.entrypoints": "web,websecure",
-				"cert-manager.io/cluster-issuer":                   d.conf.DashboardTLSClusterIssuer,
-			},
-		},
-		Spec: networkingV1.IngressSpec{
-			TLS: []networkingV1.IngressTLS{
-				{
-					Hosts:      []string{d.conf.DashboardIngressDomain},
-					SecretName: d.conf.DashboardIngressTLSSecretName,
-				},
-			},
-			Rules: []networkingV1.IngressRule{
-				{
-					Host: d.conf.DashboardIngressDomain,
-					IngressRuleValue: networkingV1.IngressRuleValue{
-						HTTP: &networkingV1.HTTPIngressRuleValue{
-							Paths: []networkingV1.HTTPIngressPath{
-								{
-									Path:     fmt.Sprintf("/%d", user.ID),
-									PathType: &pathTypePrefix,
-									Backend: networkingV1.IngressBackend{
-										Service: &networkingV1.IngressServiceBackend{
-											Name: resourceName,
-											Port: networkingV1.ServiceBackendPort{
-												Number: dashboardPort,
-											},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
+	dashboard := &models.Dashboard{UserID: user.ID}
+	if template.ID != 0 {
+		dashboard.TemplateID = &template.ID
 	}
-}
 
-func (d *DashboardService) generateRandomPassword() string {
-	return uuid.New().String()
+	release := func() {}
+
+	err = d.withDashboardLock(ctx, user.ID, func(ctx context.Context) error {
+		count, err := d.db.NewSelect().
+			Model((*models.Dashboard)(nil)).
+			Where("user_id = ?", user.ID).
+			Where("status <> ?", models.DashboardStopped).
+			Count(ctx)
+		if err != nil {
+			return err
+		}
+
+		if d.conf.DashboardLimitPerUser > 0 && count >= d.conf.DashboardLimitPerUser {
+			dashboard, err = d.templateDashboard(ctx, user, template)
+
+			return err
+		}
+
+		if err := d.checkDashboardQuota(ctx, user); err != nil {
+			return err
+		}
+
+		// The slot is released once the transaction saving the dashboard ends, for
+		// the dashboard to be counted as running by the next admissions.
+		admitted, err := d.admission.admit(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		release = admitted
+
+		return d.startDashboard(ctx, dashboard, template)
+	})
+
+	release()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Waiting happens outside the lock to let concurrent callers proceed.
+	if opts.WaitReady {
+		return dashboard, d.WaitForDashboardReady(ctx, dashboard)
+	}
+
+	return dashboard, nil
 }
 
-func (d *DashboardService) StartDashboard(ctx context.Context, user *models.User) error {
-	resourceName := fmt.Sprintf("tit-dashboard-%d", user.ID)
+// templateDashboard returns the latest dashboard of the user which is not stopped
+// and runs the template, ErrDashboardLimitReached when there is none.
+func (d *DashboardService) templateDashboard(
+	ctx context.Context,
+	user *models.User,
+	template *models.DashboardTemplate,
+) (*models.Dashboard, error) {
+	dashboard := new(models.Dashboard)
+	query := d.db.NewSelect().
+		Model(dashboard).
+		Where("user_id = ?", user.ID).
+		Where("status <> ?", models.DashboardStopped).
+		Order("id DESC").
+		Limit(1)
+
+	if template.ID == 0 {
+		query = query.Where("template_id IS NULL")
+	} else {
+		query = query.Where("template_id = ?", template.ID)
+	}
 
-	dashboardPassword := d.generateRandomPassword()
-	user.DashboardPassword = dashboardPassword
+	err := query.Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDashboardLimitReached
+	}
+
+	if err != nil {
+		return nil, err
+	}
 
-	pod := d.createPodForUser(user)
+	return dashboard, nil
+}
 
-	_, err := d.podsClient.Create(ctx, pod, metaV1.CreateOptions{})
+// startDashboard saves the dashboard record, whose ID names the dashboard
+// resources, and starts the dashboard. The record is removed when starting fails.
+func (d *DashboardService) startDashboard(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+	template *models.DashboardTemplate,
+) error {
+	now := time.Now()
+	dashboard.Status = models.DashboardStarting
+	dashboard.Password = d.generateRandomPassword()
+	dashboard.CreatedAt = now
+	dashboard.LastActivityAt = now
+
+	_, err := d.db.NewInsert().
+		Model(dashboard).
+		Returning("id").
+		Exec(ctx, &dashboard.ID)
 	if err != nil {
 		return err
 	}
 
-	service := d.createServiceForUser(user)
+	if err := d.backend.Start(ctx, dashboard, template); err != nil {
+		_, _ = d.db.NewDelete().Model(dashboard).WherePK().Exec(ctx)
+
+		return err
+	}
+
+	dashboard.Status = models.DashboardRunning
+
+	_, err = d.db.NewUpdate().
+		Model(dashboard).
+		Column("status", "password").
+		WherePK().
+		Exec(ctx)
+	if err == nil {
+		err = d.recordUsageStart(ctx, dashboard)
+	}
 
-	_, err = d.servicesClient.Create(ctx, service, metaV1.CreateOptions{})
 	if err != nil {
-		_ = d.podsClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+		_, _ = d.backend.Stop(ctx, dashboard.ID)
+		_, _ = d.db.NewDelete().Model(dashboard).WherePK().Exec(ctx)
+	}
 
+	return err
+}
+
+// GetDashboard returns the dashboard of the user with the given ID.
+func (d *DashboardService) GetDashboard(
+	ctx context.Context,
+	user *models.User,
+	dashboardID uint64,
+) (*models.Dashboard, error) {
+	dashboard := &models.Dashboard{ID: dashboardID}
+
+	err := d.db.NewSelect().
+		Model(dashboard).
+		WherePK().
+		Where("user_id = ?", user.ID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDashboardNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dashboard, nil
+}
+
+// GetCurrentDashboard returns the latest dashboard of the user which is not stopped.
+func (d *DashboardService) GetCurrentDashboard(ctx context.Context, user *models.User) (*models.Dashboard, error) {
+	dashboard := new(models.Dashboard)
+
+	err := d.db.NewSelect().
+		Model(dashboard).
+		Where("user_id = ?", user.ID).
+		Where("status <> ?", models.DashboardStopped).
+		Order("id DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDashboardNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dashboard, nil
+}
+
+// ListDashboards returns the dashboards of the user which are not stopped.
+func (d *DashboardService) ListDashboards(ctx context.Context, user *models.User) ([]*models.Dashboard, error) {
+	dashboards := make([]*models.Dashboard, 0)
+
+	err := d.db.NewSelect().
+		Model(&dashboards).
+		Where("user_id = ?", user.ID).
+		Where("status <> ?", models.DashboardStopped).
+		Order("id").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return dashboards, nil
+}
+
+// dashboardPasswdFile renders the x11vnc password file of the dashboard: the full
+// access password comes first, view-only passwords follow the __BEGIN_VIEWONLY__ line.
+func dashboardPasswdFile(dashboard *models.Dashboard) string {
+	if dashboard.ViewOnlyPassword == "" {
+		return dashboard.Password
+	}
+
+	return dashboard.Password + "\n__BEGIN_VIEWONLY__\n" + dashboard.ViewOnlyPassword
+}
+
+// RotateDashboardPassword generates a new password for the running dashboard,
+// invalidating the old one while keeping the dashboard session alive.
+func (d *DashboardService) RotateDashboardPassword(ctx context.Context, dashboard *models.Dashboard) error {
+	isDashboardRunning, err := d.IsDashboardRunning(ctx, dashboard)
+	if err != nil {
 		return err
 	}
 
-	ingress := d.createIngressForUser(user)
+	if !isDashboardRunning {
+		return ErrDashboardNotRunning
+	}
+
+	oldPassword := dashboard.Password
+	dashboard.Password = d.generateRandomPassword()
 
-	_, err = d.ingressesClient.Create(ctx, ingress, metaV1.CreateOptions{})
+	if err := d.backend.UpdatePassword(ctx, dashboard); err != nil {
+		dashboard.Password = oldPassword
+
+		return err
+	}
+
+	_, err = d.db.NewUpdate().
+		Model(dashboard).
+		Column("password").
+		WherePK().
+		Exec(ctx)
 	if err != nil {
-		_ = d.podsClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
-		_ = d.servicesClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+		dashboard.Password = oldPassword
+		_ = d.backend.UpdatePassword(ctx, dashboard)
 
 		return err
 	}
 
+	return nil
+}
+
+// WaitForDashboardReady blocks until the dashboard container becomes ready.
+// ErrDashboardReadyTimeout is returned when it takes longer than configured.
+func (d *DashboardService) WaitForDashboardReady(ctx context.Context, dashboard *models.Dashboard) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(d.conf.DashboardReadyTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var err error
+
+	if waiter, ok := d.backend.(dashboardReadyWaiter); ok {
+		err = waiter.WaitReady(ctx, dashboard.ID)
+	} else {
+		err = wait.PollImmediateUntilWithContext(ctx, dashboardPollInterval, func(ctx context.Context) (bool, error) {
+			status, err := d.backend.Status(ctx, dashboard.ID)
+			if err != nil {
+				return false, err
+			}
+
+			if status.State == DashboardStateFailed {
+				return false, fmt.Errorf("%w: %s", ErrDashboardFailed, status.Reason)
+			}
+
+			return status.Ready, nil
+		})
+	}
+
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		return ErrDashboardReadyTimeout
+	}
+
+	return err
+}
+
+// IsDashboardRunning reports whether the dashboard is being provisioned or is ready.
+func (d *DashboardService) IsDashboardRunning(ctx context.Context, dashboard *models.Dashboard) (bool, error) {
+	status, err := d.GetDashboardStatus(ctx, dashboard)
+	if err != nil {
+		return false, err
+	}
+
+	return status.State == DashboardStateProvisioning || status.State == DashboardStateReady, nil
+}
+
+func (d *DashboardService) GetDashboardStatus(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+) (*DashboardStatus, error) {
+	return d.backend.Status(ctx, dashboard.ID)
+}
+
+// StopDashboard removes the dashboard resources and marks the dashboard record
+// as stopped, clearing its password.
+func (d *DashboardService) StopDashboard(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+) (*DashboardStopResult, error) {
+	result, err := d.backend.Stop(ctx, dashboard.ID)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+
 	_, err = d.db.NewUpdate().
-		Model(user).
-		Where("id = ?", user.ID).
-		Set("dashboard_password = ?", dashboardPassword).
+		Model(dashboard).
+		Set("status = ?", models.DashboardStopped).
+		Set("password = ?", "").
+		Set("view_only_password = ?", "").
+		Set("stopped_at = ?", now).
+		WherePK().
 		Exec(ctx)
 	if err != nil {
-		_ = d.podsClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
-		_ = d.servicesClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
-		_ = d.ingressesClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+		return result, err
+	}
+
+	if err := d.recordUsageStop(ctx, dashboard, now); err != nil {
+		return result, err
+	}
+
+	dashboard.Status = models.DashboardStopped
+	dashboard.Password = ""
+	dashboard.ViewOnlyPassword = ""
+	dashboard.StoppedAt = &now
+
+	return result, nil
+}
+
+// StopUserDashboards stops every dashboard of the user which is not stopped yet
+// and returns the stopped dashboards.
+func (d *DashboardService) StopUserDashboards(ctx context.Context, user *models.User) ([]*models.Dashboard, error) {
+	dashboards, err := d.ListDashboards(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dashboard := range dashboards {
+		if _, err := d.StopDashboard(ctx, dashboard); err != nil {
+			return nil, err
+		}
+	}
+
+	return dashboards, nil
+}
+
+// ForceStopUserDashboards stops the dashboards of the user on behalf of an
+// administrator, regardless of their activity.
+func (d *DashboardService) ForceStopUserDashboards(
+	ctx context.Context,
+	user *models.User,
+) ([]*models.Dashboard, error) {
+	var dashboards []*models.Dashboard
+
+	err := d.withDashboardLock(ctx, user.ID, func(ctx context.Context) error {
+		var err error
+
+		dashboards, err = d.StopUserDashboards(ctx, user)
 
 		return err
+	})
+
+	return dashboards, err
+}
+
+// ListDashboardPods returns every dashboard pod, or container, along with the
+// owner of its dashboard.
+func (d *DashboardService) ListDashboardPods(ctx context.Context) ([]*DashboardPod, error) {
+	instances, err := d.backend.List(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	pods := make([]*DashboardPod, 0, len(instances))
+
+	for _, instance := range instances {
+		pods = append(pods, &DashboardPod{
+			Name:        instance.Name,
+			DashboardID: instance.DashboardID,
+			Phase:       instance.Phase,
+			Node:        instance.Node,
+			CreatedAt:   instance.CreatedAt,
+			Age:         time.Since(instance.CreatedAt).Round(time.Second).String(),
+		})
+	}
+
+	dashboardIDs := make([]uint64, 0, len(pods))
+
+	for _, pod := range pods {
+		if pod.DashboardID != 0 {
+			dashboardIDs = append(dashboardIDs, pod.DashboardID)
+		}
+	}
+
+	if len(dashboardIDs) == 0 {
+		return pods, nil
+	}
+
+	var owners []struct {
+		DashboardID uint64 `bun:"dashboard_id"`
+		UserID      uint64 `bun:"user_id"`
+		Email       string `bun:"email"`
+	}
+
+	err = d.db.NewSelect().
+		TableExpr("dashboards AS d").
+		ColumnExpr("d.id AS dashboard_id, d.user_id, u.email").
+		Join("JOIN users AS u ON u.id = d.user_id").
+		Where("d.id IN (?)", bun.In(dashboardIDs)).
+		Scan(ctx, &owners)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	for _, owner := range owners {
+		for _, pod := range pods {
+			if pod.DashboardID == owner.DashboardID {
+				pod.UserID = owner.UserID
+				pod.OwnerEmail = owner.Email
+			}
+		}
+	}
+
+	return pods, nil
+}
+
+// WipeHomeVolume deletes the user's home volume and stops the dashboards using it.
+// A new volume is created on the next dashboard start.
+func (d *DashboardService) WipeHomeVolume(ctx context.Context, user *models.User) error {
+	wiper, ok := d.backend.(dashboardHomeVolumeWiper)
+	if !ok {
+		return ErrHomeVolumesDisabled
+	}
+
+	return d.withDashboardLock(ctx, user.ID, func(ctx context.Context) error {
+		if err := wiper.WipeHomeVolume(ctx, user.ID); err != nil {
+			return err
+		}
+
+		_, err := d.StopUserDashboards(ctx, user)
+
+		return err
+	})
+}
+
+// TouchDashboard records user activity, postponing reaping of the idle dashboard.
+func (d *DashboardService) TouchDashboard(ctx context.Context, dashboard *models.Dashboard) error {
+	dashboard.LastActivityAt = time.Now()
+
+	_, err := d.db.NewUpdate().
+		Model(dashboard).
+		Column("last_activity_at").
+		WherePK().
+		Exec(ctx)
+
 	return err
 }
 
-func (d *DashboardService) IsDashboardRunning(ctx context.Context, user *models.User) bool {
-	_, err := d.ingressesClient.Get(ctx, fmt.Sprintf("tit-dashboard-%d", user.ID), metaV1.GetOptions{})
+// GetStudentDashboard returns the dashboard by its ID unless it belongs to an
+// instructor or a super user, whose dashboards are not open to instructors.
+func (d *DashboardService) GetStudentDashboard(ctx context.Context, dashboardID uint64) (*models.Dashboard, error) {
+	dashboard := &models.Dashboard{ID: dashboardID}
+	students := d.db.NewSelect().
+		Model((*models.User)(nil)).
+		Column("id").
+		Where("NOT is_instructor AND NOT is_super_user")
+
+	err := d.db.NewSelect().
+		Model(dashboard).
+		WherePK().
+		Where("user_id IN (?)", students).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDashboardNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dashboard, nil
+}
+
+// GrantViewOnlyAccess replaces the view-only password of the running dashboard
+// of a student and records that the instructor obtained it. The access is only
+// granted once it is recorded.
+func (d *DashboardService) GrantViewOnlyAccess(
+	ctx context.Context,
+	instructor *models.User,
+	dashboard *models.Dashboard,
+) error {
+	if dashboard.Status != models.DashboardRunning {
+		return ErrDashboardNotRunning
+	}
+
+	oldViewOnlyPassword := dashboard.ViewOnlyPassword
+	dashboard.ViewOnlyPassword = d.generateRandomPassword()
+
+	err := d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model(dashboard).
+			Column("view_only_password").
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewInsert().
+			Model(&models.DashboardAccessLog{
+				DashboardID:  dashboard.ID,
+				StudentID:    dashboard.UserID,
+				InstructorID: instructor.ID,
+				Access:       models.DashboardAccessViewOnly,
+			}).
+			Exec(ctx)
+
+		return err
+	})
+	if err != nil {
+		dashboard.ViewOnlyPassword = oldViewOnlyPassword
+
+		return err
+	}
+
+	if err := d.backend.UpdatePassword(ctx, dashboard); err != nil {
+		// The dashboard keeps the old password, the access log tells the attempt.
+		dashboard.ViewOnlyPassword = oldViewOnlyPassword
+		_, _ = d.db.NewUpdate().
+			Model(dashboard).
+			Column("view_only_password").
+			WherePK().
+			Exec(ctx)
+
+		return err
+	}
+
+	return nil
+}
+
+// ListDashboardAccessLogs returns the recorded accesses to the dashboards of the
+// student, or of all students when studentID is zero, the newest first.
+func (d *DashboardService) ListDashboardAccessLogs(
+	ctx context.Context,
+	studentID uint64,
+) ([]*models.DashboardAccessLog, error) {
+	logs := make([]*models.DashboardAccessLog, 0)
+	query := d.db.NewSelect().Model(&logs).Order("id DESC")
+
+	if studentID != 0 {
+		query = query.Where("student_id = ?", studentID)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return logs, nil
+}
+
+// DialDashboard opens a connection to the running dashboard, which the caller
+// proxies the websocket of the user through.
+func (d *DashboardService) DialDashboard(ctx context.Context, dashboard *models.Dashboard) (net.Conn, error) {
+	if dashboard.Status != models.DashboardRunning {
+		return nil, ErrDashboardNotRunning
+	}
+
+	dialer := net.Dialer{Timeout: dashboardDialTimeout}
 
-	return err == nil
+	return dialer.DialContext(ctx, "tcp", d.backend.Address(dashboard.ID))
 }
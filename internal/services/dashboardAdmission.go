@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+// Roles the caps of DashboardMaxRunningPerRole apply to.
+const (
+	DashboardRoleStudent    = "student"
+	DashboardRoleInstructor = "instructor"
+	DashboardRoleSuperUser  = "superuser"
+
+	// dashboardQueueTTL is how long a queued start keeps its place without its
+	// client asking again.
+	dashboardQueueTTL = 30 * time.Second
+)
+
+var ErrDashboardQueued = errors.New("dashboard start is queued")
+
+// DashboardQueuedError reports the place of a dashboard start waiting for a free slot.
+type DashboardQueuedError struct {
+	Position int
+}
+
+func (e *DashboardQueuedError) Error() string {
+	return fmt.Sprintf("%s at position %d", ErrDashboardQueued, e.Position)
+}
+
+func (e *DashboardQueuedError) Unwrap() error {
+	return ErrDashboardQueued
+}
+
+func dashboardRole(user *models.User) string {
+	switch {
+	case user.IsSuperUser:
+		return DashboardRoleSuperUser
+	case user.IsInstructor:
+		return DashboardRoleInstructor
+	default:
+		return DashboardRoleStudent
+	}
+}
+
+type dashboardQueueEntry struct {
+	userID uint64
+	role   string
+	seenAt time.Time
+}
+
+// dashboardAdmission caps the number of dashboards running at once, overall and
+// by role of their owners, so that the cluster is not asked for pods it has no
+// room for. Starts beyond the caps wait in a FIFO queue, holding one place per
+// user, and are admitted in order as dashboards stop. A start blocked only by the
+// cap of its role does not hold back the starts of other roles.
+//
+// Running dashboards are counted from the database, the queue and the starts in
+// progress are local to the process.
+type dashboardAdmission struct {
+	maxRunning        int
+	maxRunningPerRole map[string]int
+	countRunning      func(ctx context.Context) (map[string]int, error)
+
+	mu    sync.Mutex
+	queue []*dashboardQueueEntry
+	// starting counts the admitted starts, by role, whose dashboards may be not saved yet.
+	starting map[string]int
+}
+
+func newDashboardAdmission(
+	maxRunning int,
+	maxRunningPerRole map[string]int,
+	countRunning func(ctx context.Context) (map[string]int, error),
+) *dashboardAdmission {
+	return &dashboardAdmission{
+		maxRunning:        maxRunning,
+		maxRunningPerRole: maxRunningPerRole,
+		countRunning:      countRunning,
+		starting:          make(map[string]int),
+	}
+}
+
+func (a *dashboardAdmission) enabled() bool {
+	return a.maxRunning > 0 || len(a.maxRunningPerRole) > 0
+}
+
+// admit lets a dashboard start of the user proceed, or queues it and returns a
+// DashboardQueuedError. Clients keep their place by asking again within
+// dashboardQueueTTL. The returned function releases the slot once the started
+// dashboard is saved or the start fails.
+func (a *dashboardAdmission) admit(ctx context.Context, user *models.User) (func(), error) {
+	if !a.enabled() {
+		return func() {}, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	a.expire(now)
+
+	entry := a.find(user.ID)
+	if entry == nil {
+		entry = &dashboardQueueEntry{userID: user.ID, role: dashboardRole(user)}
+		a.queue = append(a.queue, entry)
+	}
+
+	entry.seenAt = now
+
+	running, err := a.countRunning(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+
+	for role, count := range a.starting {
+		running[role] += count
+	}
+
+	for _, count := range running {
+		total += count
+	}
+
+	for i, queued := range a.queue {
+		if a.maxRunning > 0 && total >= a.maxRunning {
+			break
+		}
+
+		if maxRunning, ok := a.maxRunningPerRole[queued.role]; ok && running[queued.role] >= maxRunning {
+			continue
+		}
+
+		if queued == entry {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+
+			return a.start(entry.role), nil
+		}
+
+		// The slot is kept for the earlier start until its client asks again.
+		running[queued.role]++
+		total++
+	}
+
+	for i, queued := range a.queue {
+		if queued == entry {
+			return nil, &DashboardQueuedError{Position: i + 1}
+		}
+	}
+
+	return nil, &DashboardQueuedError{Position: len(a.queue)}
+}
+
+func (a *dashboardAdmission) start(role string) func() {
+	a.starting[role]++
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			a.starting[role]--
+		})
+	}
+}
+
+func (a *dashboardAdmission) find(userID uint64) *dashboardQueueEntry {
+	for _, entry := range a.queue {
+		if entry.userID == userID {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// expire drops the queued starts whose clients gave up.
+func (a *dashboardAdmission) expire(now time.Time) {
+	queue := a.queue[:0]
+
+	for _, entry := range a.queue {
+		if now.Sub(entry.seenAt) < dashboardQueueTTL {
+			queue = append(queue, entry)
+		}
+	}
+
+	a.queue = queue
+}
+
+// countRunningDashboards counts the dashboards which are not stopped by role of their owners.
+func (d *DashboardService) countRunningDashboards(ctx context.Context) (map[string]int, error) {
+	var rows []struct {
+		Role  string `bun:"role"`
+		Count int    `bun:"count"`
+	}
+
+	err := d.db.NewSelect().
+		TableExpr("dashboards AS d").
+		ColumnExpr(
+			"CASE WHEN u.is_super_user THEN ? WHEN u.is_instructor THEN ? ELSE ? END AS role",
+			DashboardRoleSuperUser, DashboardRoleInstructor, DashboardRoleStudent,
+		).
+		ColumnExpr("count(*) AS count").
+		Join("JOIN users AS u ON u.id = d.user_id").
+		Where("d.status <> ?", models.DashboardStopped).
+		GroupExpr("role").
+		Scan(ctx, &rows)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	running := make(map[string]int, len(rows))
+
+	for _, row := range rows {
+		running[row.Role] = row.Count
+	}
+
+	return running, nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+func setupDashboardAdmission(
+	maxRunning int,
+	maxRunningPerRole map[string]int,
+	running map[string]int,
+) *dashboardAdmission {
+	return newDashboardAdmission(maxRunning, maxRunningPerRole, func(context.Context) (map[string]int, error) {
+		counts := make(map[string]int, len(running))
+		for role, count := range running {
+			counts[role] = count
+		}
+
+		return counts, nil
+	})
+}
+
+func queuePosition(t *testing.T, err error) int {
+	t.Helper()
+
+	var queued *DashboardQueuedError
+
+	assert.Equal(t, errors.As(err, &queued), true)
+
+	return queued.Position
+}
+
+func TestDashboardAdmissionDisabled(t *testing.T) {
+	admission := setupDashboardAdmission(0, nil, nil)
+
+	release, err := admission.admit(context.Background(), &models.User{ID: 1})
+	assert.Equal(t, err, nil)
+	release()
+}
+
+func TestDashboardAdmissionQueuesInOrder(t *testing.T) {
+	running := map[string]int{DashboardRoleStudent: 1}
+	admission := setupDashboardAdmission(2, nil, running)
+	ctx := context.Background()
+
+	release, err := admission.admit(ctx, &models.User{ID: 1})
+	assert.Equal(t, err, nil)
+
+	_, err = admission.admit(ctx, &models.User{ID: 2})
+	assert.Equal(t, queuePosition(t, err), 1)
+
+	_, err = admission.admit(ctx, &models.User{ID: 3})
+	assert.Equal(t, queuePosition(t, err), 2)
+
+	// The dashboard of user 1 is saved while another one stops, the freed slot is
+	// kept for user 2, who asked first.
+	release()
+
+	_, err = admission.admit(ctx, &models.User{ID: 3})
+	assert.Equal(t, queuePosition(t, err), 2)
+
+	_, err = admission.admit(ctx, &models.User{ID: 2})
+	assert.Equal(t, err, nil)
+
+	_, err = admission.admit(ctx, &models.User{ID: 3})
+	assert.Equal(t, queuePosition(t, err), 1)
+}
+
+func TestDashboardAdmissionRoleCaps(t *testing.T) {
+	running := map[string]int{DashboardRoleStudent: 1}
+	admission := setupDashboardAdmission(0, map[string]int{DashboardRoleStudent: 1}, running)
+	ctx := context.Background()
+
+	_, err := admission.admit(ctx, &models.User{ID: 1})
+	assert.Equal(t, queuePosition(t, err), 1)
+
+	// Instructors are not held back by the queued students.
+	_, err = admission.admit(ctx, &models.User{ID: 2, IsInstructor: true})
+	assert.Equal(t, err, nil)
+}
+
+func TestDashboardAdmissionDropsAbandonedStarts(t *testing.T) {
+	admission := setupDashboardAdmission(1, nil, map[string]int{DashboardRoleStudent: 1})
+	ctx := context.Background()
+
+	_, err := admission.admit(ctx, &models.User{ID: 1})
+	assert.Equal(t, queuePosition(t, err), 1)
+
+	admission.queue[0].seenAt = time.Now().Add(-dashboardQueueTTL)
+
+	_, err = admission.admit(ctx, &models.User{ID: 2})
+	assert.Equal(t, queuePosition(t, err), 1)
+}
+
+func TestCreateDashboardQueuedAtCapacity(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	dashboardService.admission.maxRunning = 1
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(1, 1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT CASE WHEN u.is_super_user THEN 'superuser' (.+) GROUP BY role").
+		WillReturnRows(sqlmock.NewRows([]string{"role", "count"}).AddRow(DashboardRoleStudent, 1))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, queuePosition(t, err), 1)
+	assert.Equal(t, errors.Is(err, ErrDashboardQueued), true)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
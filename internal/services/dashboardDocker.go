@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+// maxDockerPort is the highest port a dashboard container can be published at.
+const maxDockerPort = 65535
+
+var (
+	errNoSuchContainer   = errors.New("no such container")
+	errInvalidDockerPort = errors.New("dashboard port is out of range, lower DASHBOARD_DOCKER_PORT_BASE")
+)
+
+type dockerContainer struct {
+	Name    string    `json:"Name"`
+	Created time.Time `json:"Created"`
+	State   struct {
+		Status   string `json:"Status"`
+		Running  bool   `json:"Running"`
+		ExitCode int    `json:"ExitCode"`
+		Error    string `json:"Error"`
+	} `json:"State"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// DockerDashboardBackend runs every dashboard as a local container, which is
+// enough for development without a Kubernetes cluster. A dashboard is published
+// on localhost at DashboardDockerPortBase + dashboard ID.
+type DockerDashboardBackend struct {
+	conf *core.Config
+}
+
+func NewDockerDashboardBackend(conf *core.Config) *DockerDashboardBackend {
+	return &DockerDashboardBackend{conf}
+}
+
+func (d *DockerDashboardBackend) run(ctx context.Context, env []string, args ...string) ([]byte, error) {
+	stderr := new(bytes.Buffer)
+
+	cmd := exec.CommandContext(ctx, d.conf.DashboardDockerBinary, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if strings.Contains(message, "No such") {
+			return nil, fmt.Errorf("%w: %s", errNoSuchContainer, message)
+		}
+
+		return nil, fmt.Errorf("%s %s: %w: %s", d.conf.DashboardDockerBinary, args[0], err, message)
+	}
+
+	return output, nil
+}
+
+func (d *DockerDashboardBackend) inspect(ctx context.Context, names ...string) ([]dockerContainer, error) {
+	output, err := d.run(ctx, nil, append([]string{"inspect", "--type", "container"}, names...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []dockerContainer
+
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
+// Start runs the dashboard container of the template. Resources of the template
+// are not applied to local containers.
+func (d *DockerDashboardBackend) Start(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+	template *models.DashboardTemplate,
+) error {
+	port, err := d.port(dashboard.ID)
+	if err != nil {
+		return err
+	}
+
+	// Values are passed through the environment to keep them out of the process list.
+	env := []string{"PASSWORD=" + dashboard.Password}
+	args := []string{
+		"run", "--detach",
+		"--name", dashboardResourceName(dashboard.ID),
+		"--label", "tier=dashboard",
+		"--label", fmt.Sprintf("dashboard-id=%d", dashboard.ID),
+		"--label", fmt.Sprintf("user-id=%d", dashboard.UserID),
+		"--label", fmt.Sprintf("template-id=%d", template.ID),
+		"--env", "PASSWORD",
+		"--publish", fmt.Sprintf("127.0.0.1:%d:%d", port, dashboardTemplatePort(template)),
+	}
+
+	for name, value := range template.Env {
+		env = append(env, name+"="+value)
+		args = append(args, "--env", name)
+	}
+
+	_, err = d.run(ctx, env, append(args, template.Image)...)
+	if err != nil {
+		_, _ = d.Stop(ctx, dashboard.ID)
+	}
+
+	return err
+}
+
+func (d *DockerDashboardBackend) port(dashboardID uint64) (int, error) {
+	if dashboardID > maxDockerPort || d.conf.DashboardDockerPortBase+int(dashboardID) > maxDockerPort {
+		return 0, fmt.Errorf("%w: %d + %d", errInvalidDockerPort, d.conf.DashboardDockerPortBase, dashboardID)
+	}
+
+	return d.conf.DashboardDockerPortBase + int(dashboardID), nil
+}
+
+// Address is the published port of the dashboard container. Dashboards beyond
+// the valid ports never start, so their zero port is not dialed successfully.
+func (d *DockerDashboardBackend) Address(dashboardID uint64) string {
+	port, _ := d.port(dashboardID)
+
+	return fmt.Sprintf("127.0.0.1:%d", port)
+}
+
+// UpdatePassword overwrites the password file which x11vnc re-reads inside the container.
+func (d *DockerDashboardBackend) UpdatePassword(ctx context.Context, dashboard *models.Dashboard) error {
+	_, err := d.run(ctx, []string{"PASSWORD=" + dashboardPasswdFile(dashboard)},
+		"exec", "--env", "PASSWORD", dashboardResourceName(dashboard.ID),
+		"sh", "-c", `printf '%s\n' "$PASSWORD" > `+dashboardPasswordFile,
+	)
+
+	return err
+}
+
+func (d *DockerDashboardBackend) Stop(ctx context.Context, dashboardID uint64) (*DashboardStopResult, error) {
+	result := &DashboardStopResult{Deleted: []string{}}
+
+	_, err := d.run(ctx, nil, "rm", "--force", dashboardResourceName(dashboardID))
+	if errors.Is(err, errNoSuchContainer) {
+		return result, nil
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Deleted = append(result.Deleted, "container")
+
+	return result, nil
+}
+
+func (d *DockerDashboardBackend) Status(ctx context.Context, dashboardID uint64) (*DashboardStatus, error) {
+	containers, err := d.inspect(ctx, dashboardResourceName(dashboardID))
+	if errors.Is(err, errNoSuchContainer) || (err == nil && len(containers) == 0) {
+		return &DashboardStatus{State: DashboardStateStopped}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := containers[0].State
+	status := &DashboardStatus{Ready: state.Running}
+	status.TemplateID, _ = strconv.ParseUint(containers[0].Config.Labels["template-id"], 10, 64)
+
+	switch state.Status {
+	case "running":
+		status.State = DashboardStateReady
+	case "created", "restarting":
+		status.State = DashboardStateProvisioning
+	case "removing":
+		status.State = DashboardStateTerminating
+	default:
+		status.State = DashboardStateFailed
+		status.Reason = fmt.Sprintf("container is %s with exit code %d", state.Status, state.ExitCode)
+
+		if state.Error != "" {
+			status.Reason = state.Error
+		}
+	}
+
+	return status, nil
+}
+
+// List returns every tier=dashboard container, the stopped ones included.
+func (d *DockerDashboardBackend) List(ctx context.Context) ([]DashboardInstance, error) {
+	output, err := d.run(ctx, nil, "ps", "--all", "--quiet", "--filter", "label=tier=dashboard")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := strings.Fields(string(output))
+	if len(ids) == 0 {
+		return []DashboardInstance{}, nil
+	}
+
+	containers, err := d.inspect(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]DashboardInstance, 0, len(containers))
+
+	for _, container := range containers {
+		dashboardID, _ := strconv.ParseUint(container.Config.Labels["dashboard-id"], 10, 64)
+
+		instances = append(instances, DashboardInstance{
+			Name:        strings.TrimPrefix(container.Name, "/"),
+			DashboardID: dashboardID,
+			Phase:       container.State.Status,
+			CreatedAt:   container.Created,
+		})
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].CreatedAt.Before(instances[j].CreatedAt)
+	})
+
+	return instances, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+// dockerStub records the arguments and the dashboard environment of every call
+// to args and env in its directory, and answers a command with the content of
+// <command>.out, or fails with the content of <command>.err.
+const dockerStub = `#!/bin/sh
+dir=$(dirname "$0")
+printf '%s\n' "$@" >> "$dir/args"
+printf 'PASSWORD=%s LANG=%s\n' "$PASSWORD" "$LANG" >> "$dir/env"
+
+if [ -f "$dir/$1.err" ]; then
+    cat "$dir/$1.err" >&2
+    exit 1
+fi
+
+if [ -f "$dir/$1.out" ]; then
+    cat "$dir/$1.out"
+fi
+`
+
+func setupDockerDashboardBackend(t *testing.T) (*DockerDashboardBackend, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "docker")
+
+	assert.Equal(t, os.WriteFile(binary, []byte(dockerStub), 0o700), nil)
+
+	return NewDockerDashboardBackend(&core.Config{
+		DashboardDockerBinary:   binary,
+		DashboardDockerPortBase: 20000,
+	}), dir
+}
+
+func writeDockerStubFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600), nil)
+}
+
+func readDockerStubFile(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	assert.Equal(t, err, nil)
+
+	return string(content)
+}
+
+func TestDockerDashboardStart(t *testing.T) {
+	backend, dir := setupDockerDashboardBackend(t)
+	dashboard := &models.Dashboard{ID: 1, UserID: 2, Password: "secret"}
+	template := &models.DashboardTemplate{ID: 3, Image: "python:3", Env: map[string]string{"LANG": "C"}}
+
+	err := backend.Start(context.Background(), dashboard, template)
+	assert.Equal(t, err, nil)
+
+	// Values are passed through the environment only.
+	assert.Equal(t, strings.Fields(readDockerStubFile(t, dir, "args")), []string{
+		"run", "--detach",
+		"--name", "tit-dashboard-1",
+		"--label", "tier=dashboard",
+		"--label", "dashboard-id=1",
+		"--label", "user-id=2",
+		"--label", "template-id=3",
+		"--env", "PASSWORD",
+		"--publish", "127.0.0.1:20001:8888",
+		"--env", "LANG",
+		"python:3",
+	})
+	assert.Equal(t, readDockerStubFile(t, dir, "env"), "PASSWORD=secret LANG=C\n")
+	assert.Equal(t, backend.Address(1), "127.0.0.1:20001")
+}
+
+func TestDockerDashboardStartOutOfPorts(t *testing.T) {
+	backend, dir := setupDockerDashboardBackend(t)
+	dashboard := &models.Dashboard{ID: 45536, UserID: 2, Password: "secret"}
+
+	err := backend.Start(context.Background(), dashboard, &models.DashboardTemplate{Image: "python:3"})
+	assert.Equal(t, errors.Is(err, errInvalidDockerPort), true)
+
+	_, err = os.Stat(filepath.Join(dir, "args"))
+	assert.Equal(t, os.IsNotExist(err), true)
+}
+
+func TestDockerDashboardStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		inspect string
+		state   DashboardState
+		reason  string
+		ready   bool
+	}{
+		{
+			name:    "running",
+			inspect: `[{"State": {"Status": "running", "Running": true}, "Config": {"Labels": {"template-id": "3"}}}]`,
+			state:   DashboardStateReady,
+			ready:   true,
+		},
+		{
+			name:    "created",
+			inspect: `[{"State": {"Status": "created"}}]`,
+			state:   DashboardStateProvisioning,
+		},
+		{
+			name:    "exited",
+			inspect: `[{"State": {"Status": "exited", "ExitCode": 1}}]`,
+			state:   DashboardStateFailed,
+			reason:  "container is exited with exit code 1",
+		},
+		{
+			name:    "failed to run",
+			inspect: `[{"State": {"Status": "exited", "ExitCode": 127, "Error": "executable file not found"}}]`,
+			state:   DashboardStateFailed,
+			reason:  "executable file not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend, dir := setupDockerDashboardBackend(t)
+			writeDockerStubFile(t, dir, "inspect.out", test.inspect)
+
+			status, err := backend.Status(context.Background(), 1)
+			assert.Equal(t, err, nil)
+			assert.Equal(t, status.State, test.state)
+			assert.Equal(t, status.Reason, test.reason)
+			assert.Equal(t, status.Ready, test.ready)
+		})
+	}
+
+	backend, dir := setupDockerDashboardBackend(t)
+	writeDockerStubFile(t, dir, "inspect.err", "Error: No such container: tit-dashboard-1")
+
+	status, err := backend.Status(context.Background(), 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status.State, DashboardStateStopped)
+}
+
+func TestDockerDashboardList(t *testing.T) {
+	backend, dir := setupDockerDashboardBackend(t)
+	writeDockerStubFile(t, dir, "ps.out", "b2\na1\n")
+	writeDockerStubFile(t, dir, "inspect.out", `[
+		{
+			"Name": "/tit-dashboard-2",
+			"Created": "2023-07-01T12:00:00Z",
+			"State": {"Status": "running"},
+			"Config": {"Labels": {"dashboard-id": "2"}}
+		},
+		{
+			"Name": "/tit-dashboard-1",
+			"Created": "2023-07-01T11:00:00Z",
+			"State": {"Status": "exited"},
+			"Config": {"Labels": {"dashboard-id": "1"}}
+		}
+	]`)
+
+	instances, err := backend.List(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(instances), 2)
+	assert.Equal(t, instances[0].Name, "tit-dashboard-1")
+	assert.Equal(t, instances[0].DashboardID, uint64(1))
+	assert.Equal(t, instances[0].Phase, "exited")
+	assert.Equal(t, instances[1].DashboardID, uint64(2))
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type DashboardEventType string
+
+const (
+	DashboardEventScheduled    DashboardEventType = "scheduled"
+	DashboardEventPullingImage DashboardEventType = "pulling-image"
+	DashboardEventReady        DashboardEventType = "ready"
+	DashboardEventFailed       DashboardEventType = "failed"
+	DashboardEventTerminated   DashboardEventType = "terminated"
+	DashboardEventReaped       DashboardEventType = "reaped"
+)
+
+const (
+	// dashboardEventBuffer events wait for a slow subscriber, later ones are dropped.
+	dashboardEventBuffer        = 16
+	dashboardWatchRetryInterval = 5 * time.Second
+)
+
+// DashboardEvent is a step of the dashboard lifecycle.
+type DashboardEvent struct {
+	DashboardID uint64             `json:"dashboardId"`
+	Type        DashboardEventType `json:"type"`
+	Reason      string             `json:"reason,omitempty"`
+	Time        time.Time          `json:"time"`
+}
+
+// dashboardEventBus fans events out to the subscribers of the dashboard owner. It
+// is local to the process, every API replica watches the cluster on its own.
+type dashboardEventBus struct {
+	mu          sync.Mutex
+	subscribers map[uint64]map[chan DashboardEvent]struct{}
+}
+
+func newDashboardEventBus() *dashboardEventBus {
+	return &dashboardEventBus{subscribers: make(map[uint64]map[chan DashboardEvent]struct{})}
+}
+
+func (b *dashboardEventBus) subscribe(userID uint64) (<-chan DashboardEvent, func()) {
+	events := make(chan DashboardEvent, dashboardEventBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan DashboardEvent]struct{})
+	}
+
+	b.subscribers[userID][events] = struct{}{}
+
+	return events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers[userID], events)
+
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+	}
+}
+
+func (b *dashboardEventBus) publish(userID uint64, event DashboardEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers[userID] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeDashboardEvents streams the lifecycle events of the dashboards of the
+// user until the returned function is called.
+func (d *DashboardService) SubscribeDashboardEvents(user *models.User) (<-chan DashboardEvent, func()) {
+	return d.events.subscribe(user.ID)
+}
+
+// publishDashboardEvent delivers the event to the owner of the dashboard. Events of
+// dashboards without a record are dropped.
+func (d *DashboardService) publishDashboardEvent(ctx context.Context, event DashboardEvent) error {
+	var userID uint64
+
+	err := d.db.NewSelect().
+		Model((*models.Dashboard)(nil)).
+		Column("user_id").
+		Where("id = ?", event.DashboardID).
+		Scan(ctx, &userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	d.events.publish(userID, event)
+
+	return nil
+}
+
+// DashboardEventWatcher translates watch events of the dashboard pods, and the
+// Kubernetes events about pulling their images, into dashboard lifecycle events.
+type DashboardEventWatcher struct {
+	dashboardService *DashboardService
+	logger           *core.Logger
+
+	mu sync.Mutex
+	// podDashboards maps the names of the dashboard pods to their dashboards, as
+	// Kubernetes events only refer to pods by name.
+	podDashboards map[string]uint64
+	// lastEvents keeps the last event of every dashboard pod to skip repeated ones.
+	lastEvents map[string]DashboardEventType
+}
+
+func NewDashboardEventWatcher(dashboardService *DashboardService, logger *core.Logger) *DashboardEventWatcher {
+	return &DashboardEventWatcher{
+		dashboardService: dashboardService,
+		logger:           logger,
+		podDashboards:    make(map[string]uint64),
+		lastEvents:       make(map[string]DashboardEventType),
+	}
+}
+
+// Run blocks until ctx is done, rewatching whenever a watch ends.
+func (w *DashboardEventWatcher) Run(ctx context.Context) {
+	backend, ok := w.dashboardService.backend.(*KubernetesDashboardBackend)
+	if !ok {
+		w.logger.Info().Msg("Dashboard event watcher is disabled")
+
+		return
+	}
+
+	eventsClient := backend.clientSet.CoreV1().Events(backend.conf.KubernetesDashboardNamespace)
+
+	go w.watchLoop(ctx, func(ctx context.Context) (watch.Interface, error) {
+		return eventsClient.Watch(ctx, metaV1.ListOptions{FieldSelector: "involvedObject.kind=Pod,reason=Pulling"})
+	}, w.handleKubernetesEvent)
+
+	w.watchLoop(ctx, func(ctx context.Context) (watch.Interface, error) {
+		return backend.podsClient.Watch(ctx, metaV1.ListOptions{LabelSelector: "tier=dashboard"})
+	}, w.handlePodEvent)
+}
+
+func (w *DashboardEventWatcher) watchLoop(
+	ctx context.Context,
+	start func(ctx context.Context) (watch.Interface, error),
+	handle func(ctx context.Context, event watch.Event),
+) {
+	for {
+		watcher, err := start(ctx)
+		if err != nil {
+			w.logger.Err(err).Msg("dashboard event watching")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(dashboardWatchRetryInterval):
+				continue
+			}
+		}
+
+		if !w.consume(ctx, watcher, handle) {
+			return
+		}
+	}
+}
+
+// consume handles the events of watcher until it ends, returning false when
+// ctx is done.
+func (w *DashboardEventWatcher) consume(
+	ctx context.Context,
+	watcher watch.Interface,
+	handle func(ctx context.Context, event watch.Event),
+) bool {
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return true
+			}
+
+			handle(ctx, event)
+		}
+	}
+}
+
+func (w *DashboardEventWatcher) handlePodEvent(ctx context.Context, event watch.Event) {
+	pod, ok := event.Object.(*coreV1.Pod)
+	if !ok {
+		return
+	}
+
+	dashboardID, ok := parseDashboardID(pod.Labels)
+	if !ok {
+		return
+	}
+
+	if event.Type == watch.Deleted {
+		w.forget(pod.Name)
+		w.publish(ctx, DashboardEvent{DashboardID: dashboardID, Type: DashboardEventTerminated})
+
+		return
+	}
+
+	eventType, reason := podLifecycleEvent(pod)
+	if eventType == "" || !w.record(pod.Name, dashboardID, eventType) {
+		return
+	}
+
+	w.publish(ctx, DashboardEvent{DashboardID: dashboardID, Type: eventType, Reason: reason})
+}
+
+func (w *DashboardEventWatcher) handleKubernetesEvent(ctx context.Context, event watch.Event) {
+	kubernetesEvent, ok := event.Object.(*coreV1.Event)
+	if !ok || event.Type == watch.Deleted {
+		return
+	}
+
+	podName := kubernetesEvent.InvolvedObject.Name
+
+	w.mu.Lock()
+	dashboardID, ok := w.podDashboards[podName]
+	w.mu.Unlock()
+
+	if !ok || !w.record(podName, dashboardID, DashboardEventPullingImage) {
+		return
+	}
+
+	w.publish(ctx, DashboardEvent{
+		DashboardID: dashboardID,
+		Type:        DashboardEventPullingImage,
+		Reason:      kubernetesEvent.Message,
+	})
+}
+
+// record remembers the event of the pod, reporting whether it moves the dashboard
+// forward. Pulling the image only follows scheduling and, until the pod becomes
+// ready, its scheduled state is not reported again.
+func (w *DashboardEventWatcher) record(podName string, dashboardID uint64, eventType DashboardEventType) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.podDashboards[podName] = dashboardID
+	lastEvent := w.lastEvents[podName]
+
+	switch {
+	case eventType == lastEvent:
+		return false
+	case eventType == DashboardEventPullingImage && lastEvent != "" && lastEvent != DashboardEventScheduled:
+		return false
+	case eventType == DashboardEventScheduled && lastEvent == DashboardEventPullingImage:
+		return false
+	}
+
+	w.lastEvents[podName] = eventType
+
+	return true
+}
+
+func (w *DashboardEventWatcher) forget(podName string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.podDashboards, podName)
+	delete(w.lastEvents, podName)
+}
+
+func (w *DashboardEventWatcher) publish(ctx context.Context, event DashboardEvent) {
+	event.Time = time.Now()
+
+	if err := w.dashboardService.publishDashboardEvent(ctx, event); err != nil {
+		w.logger.Err(err).Uint64("dashboardId", event.DashboardID).Msg("dashboard event publishing")
+	}
+}
+
+// podLifecycleEvent derives the lifecycle event from the state of the pod, it is
+// empty while the pod waits to be scheduled or terminates.
+func podLifecycleEvent(pod *coreV1.Pod) (DashboardEventType, string) {
+	if pod.DeletionTimestamp != nil {
+		return "", ""
+	}
+
+	ready, failureReason := dashboardPodReadiness(pod)
+
+	switch {
+	case failureReason != "":
+		return DashboardEventFailed, failureReason
+	case ready:
+		return DashboardEventReady, ""
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreV1.PodScheduled && condition.Status == coreV1.ConditionTrue {
+			return DashboardEventScheduled, ""
+		}
+	}
+
+	return "", ""
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func expectDashboardOwner(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT \"d\".\"user_id\" FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+}
+
+func receiveDashboardEvents(events <-chan DashboardEvent) []DashboardEventType {
+	var received []DashboardEventType
+
+	for {
+		select {
+		case event := <-events:
+			received = append(received, event.Type)
+		default:
+			return received
+		}
+	}
+}
+
+func TestDashboardEventBus(t *testing.T) {
+	bus := newDashboardEventBus()
+	events, unsubscribe := bus.subscribe(1)
+	otherEvents, unsubscribeOther := bus.subscribe(2)
+
+	defer unsubscribeOther()
+
+	bus.publish(1, DashboardEvent{DashboardID: 1, Type: DashboardEventReady})
+	assert.Equal(t, receiveDashboardEvents(events), []DashboardEventType{DashboardEventReady})
+	assert.Equal(t, len(receiveDashboardEvents(otherEvents)), 0)
+
+	unsubscribe()
+	bus.publish(1, DashboardEvent{DashboardID: 1, Type: DashboardEventTerminated})
+	assert.Equal(t, len(receiveDashboardEvents(events)), 0)
+	assert.Equal(t, len(bus.subscribers), 1)
+}
+
+func TestDashboardEventBusDropsEventsOfSlowSubscribers(t *testing.T) {
+	bus := newDashboardEventBus()
+	events, unsubscribe := bus.subscribe(1)
+
+	defer unsubscribe()
+
+	for i := 0; i < dashboardEventBuffer+1; i++ {
+		bus.publish(1, DashboardEvent{DashboardID: 1, Type: DashboardEventReady})
+	}
+
+	assert.Equal(t, len(receiveDashboardEvents(events)), dashboardEventBuffer)
+}
+
+func TestDashboardEventWatcherTranslatesPodEvents(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	watcher := NewDashboardEventWatcher(dashboardService, core.NewLogger(&core.Config{}))
+	events, unsubscribe := dashboardService.SubscribeDashboardEvents(&models.User{ID: 1})
+	ctx := context.Background()
+
+	defer unsubscribe()
+
+	pod := newDashboardPod(coreV1.PodPending, coreV1.ContainerStatus{})
+	watcher.handlePodEvent(ctx, watch.Event{Type: watch.Added, Object: pod.DeepCopy()})
+
+	pod.Status.Conditions = []coreV1.PodCondition{{Type: coreV1.PodScheduled, Status: coreV1.ConditionTrue}}
+
+	expectDashboardOwner(mock)
+	watcher.handlePodEvent(ctx, watch.Event{Type: watch.Modified, Object: pod.DeepCopy()})
+	watcher.handlePodEvent(ctx, watch.Event{Type: watch.Modified, Object: pod.DeepCopy()})
+
+	expectDashboardOwner(mock)
+	watcher.handleKubernetesEvent(ctx, watch.Event{Type: watch.Added, Object: &coreV1.Event{
+		InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: pod.Name},
+		Reason:         "Pulling",
+		Message:        "Pulling image \"tit-dashboard:latest\"",
+	}})
+
+	pod.Status.Phase = coreV1.PodRunning
+	pod.Status.Conditions = append(pod.Status.Conditions, coreV1.PodCondition{
+		Type:   coreV1.PodReady,
+		Status: coreV1.ConditionTrue,
+	})
+	pod.Status.ContainerStatuses[0].Ready = true
+
+	expectDashboardOwner(mock)
+	watcher.handlePodEvent(ctx, watch.Event{Type: watch.Modified, Object: pod.DeepCopy()})
+
+	expectDashboardOwner(mock)
+	watcher.handlePodEvent(ctx, watch.Event{Type: watch.Deleted, Object: pod.DeepCopy()})
+
+	assert.Equal(t, receiveDashboardEvents(events), []DashboardEventType{
+		DashboardEventScheduled,
+		DashboardEventPullingImage,
+		DashboardEventReady,
+		DashboardEventTerminated,
+	})
+	assert.Equal(t, len(watcher.lastEvents), 0)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestDashboardEventWatcherReportsFailures(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	watcher := NewDashboardEventWatcher(dashboardService, core.NewLogger(&core.Config{}))
+	events, unsubscribe := dashboardService.SubscribeDashboardEvents(&models.User{ID: 1})
+
+	defer unsubscribe()
+
+	pod := newDashboardPod(coreV1.PodPending, coreV1.ContainerStatus{
+		State: coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+	})
+
+	expectDashboardOwner(mock)
+	watcher.handlePodEvent(context.Background(), watch.Event{Type: watch.Modified, Object: pod})
+
+	event := <-events
+	assert.Equal(t, event.Type, DashboardEventFailed)
+	assert.Equal(t, event.Reason, "ImagePullBackOff")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestDashboardEventWatcherIgnoresPoolPods(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	watcher := NewDashboardEventWatcher(dashboardService, core.NewLogger(&core.Config{}))
+	pod := &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Name:   "tit-dashboard-pool-abc",
+		Labels: map[string]string{"tier": "dashboard", "app": "tit-dashboard-pool-abc"},
+	}}
+
+	watcher.handlePodEvent(context.Background(), watch.Event{Type: watch.Deleted, Object: pod})
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	networkingV1 "k8s.io/api/networking/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	typedNetworkingV1 "k8s.io/client-go/kubernetes/typed/networking/v1"
+)
+
+const (
+	DashboardIngressTraefik = "traefik"
+	DashboardIngressNginx   = "nginx"
+	DashboardIngressGateway = "gateway"
+	// DashboardIngressNone leaves dashboards reachable only through the API proxy.
+	DashboardIngressNone = "none"
+
+	// nginxWebsocketTimeout keeps idle VNC websockets open, ingress-nginx closes
+	// proxied connections after 60 seconds of silence by default.
+	nginxWebsocketTimeout = "3600"
+)
+
+var (
+	errInvalidIngressStrategy = errors.New("invalid dashboard ingress strategy")
+	errWildcardTLSSecretName  = errors.New("dashboard subdomains require a wildcard TLS secret name")
+)
+
+var httpRouteResource = schema.GroupVersionResource{
+	Group:    "gateway.networking.k8s.io",
+	Version:  "v1beta1",
+	Resource: "httproutes",
+}
+
+// dashboardRouter publishes the dashboard service outside the cluster. Whatever
+// resource it manages, the resource is reported as the "ingress" kind.
+type dashboardRouter interface {
+	dashboardResourceDeleter
+	Create(ctx context.Context, dashboard *models.Dashboard) error
+	Exists(ctx context.Context, name string) (bool, error)
+	List(ctx context.Context, opts metaV1.ListOptions) ([]metaV1.ObjectMeta, error)
+}
+
+func newDashboardRouter(
+	conf *core.Config,
+	clientSet kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+) (dashboardRouter, error) {
+	ingressesClient := clientSet.NetworkingV1().Ingresses(conf.KubernetesDashboardNamespace)
+
+	// Every subdomain ingress refers to the same secret, which must already hold
+	// a certificate for *.<domain>: cert-manager would issue it for one host only.
+	if conf.DashboardIngressSubdomains && conf.DashboardIngressTLSSecretName == "" &&
+		conf.DashboardIngressStrategy != DashboardIngressGateway {
+		return nil, errWildcardTLSSecretName
+	}
+
+	switch conf.DashboardIngressStrategy {
+	case "", DashboardIngressTraefik:
+		return &ingressRouter{conf, ingressesClient, map[string]string{
+			"traefik.ingress.kubernetes.io/router.entrypoints": "web,websecure",
+		}}, nil
+	case DashboardIngressNginx:
+		return &ingressRouter{conf, ingressesClient, map[string]string{
+			"nginx.ingress.kubernetes.io/proxy-read-timeout": nginxWebsocketTimeout,
+			"nginx.ingress.kubernetes.io/proxy-send-timeout": nginxWebsocketTimeout,
+		}}, nil
+	case DashboardIngressGateway:
+		if conf.DashboardGatewayName == "" {
+			return nil, fmt.Errorf("%w %q: gateway name is empty", errInvalidIngressStrategy, conf.DashboardIngressStrategy)
+		}
+
+		if dynamicClient == nil {
+			return nil, fmt.Errorf("%w %q: no dynamic client", errInvalidIngressStrategy, conf.DashboardIngressStrategy)
+		}
+
+		return &gatewayRouter{
+			conf,
+			dynamicClient.Resource(httpRouteResource).Namespace(conf.KubernetesDashboardNamespace),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", errInvalidIngressStrategy, conf.DashboardIngressStrategy)
+	}
+}
+
+// dashboardAddress returns the host and the path prefix the dashboard is served at:
+// either its own subdomain or a path on the shared dashboards domain.
+func dashboardAddress(conf *core.Config, dashboardID uint64) (string, string) {
+	if conf.DashboardIngressSubdomains {
+		return fmt.Sprintf("%d.%s", dashboardID, conf.DashboardIngressDomain), "/"
+	}
+
+	return conf.DashboardIngressDomain, fmt.Sprintf("/%d", dashboardID)
+}
+
+// ingressRouter publishes dashboards through networking.k8s.io Ingresses, the
+// annotations tune them for the ingress controller in use.
+type ingressRouter struct {
+	conf        *core.Config
+	client      typedNetworkingV1.IngressInterface
+	annotations map[string]string
+}
+
+func (r *ingressRouter) createIngressForDashboard(dashboard *models.Dashboard) *networkingV1.Ingress {
+	resourceName := dashboardResourceName(dashboard.ID)
+	host, path := dashboardAddress(r.conf, dashboard.ID)
+	pathTypePrefix := networkingV1.PathTypePrefix
+	annotations := make(map[string]string, len(r.annotations)+1)
+
+	for key, value := range r.annotations {
+		annotations[key] = value
+	}
+
+	if r.conf.DashboardTLSClusterIssuer != "" && !r.conf.DashboardIngressSubdomains {
+		annotations["cert-manager.io/cluster-issuer"] = r.conf.DashboardTLSClusterIssuer
+	}
+
+	ingress := &networkingV1.Ingress{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        resourceName,
+			Labels:      dashboardLabels(dashboard.ID),
+			Annotations: annotations,
+		},
+		Spec: networkingV1.IngressSpec{
+			TLS: []networkingV1.IngressTLS{
+				{
+					Hosts:      []string{host},
+					SecretName: r.conf.DashboardIngressTLSSecretName,
+				},
+			},
+			Rules: []networkingV1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingV1.IngressRuleValue{
+						HTTP: &networkingV1.HTTPIngressRuleValue{
+							Paths: []networkingV1.HTTPIngressPath{
+								{
+									Path:     path,
+									PathType: &pathTypePrefix,
+									Backend: networkingV1.IngressBackend{
+										Service: &networkingV1.IngressServiceBackend{
+											Name: resourceName,
+											Port: networkingV1.ServiceBackendPort{
+												Number: dashboardPort,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if r.conf.DashboardIngressClassName != "" {
+		ingress.Spec.IngressClassName = &r.conf.DashboardIngressClassName
+	}
+
+	return ingress
+}
+
+func (r *ingressRouter) Create(ctx context.Context, dashboard *models.Dashboard) error {
+	_, err := r.client.Create(ctx, r.createIngressForDashboard(dashboard), metaV1.CreateOptions{})
+
+	return err
+}
+
+func (r *ingressRouter) Delete(ctx context.Context, name string, opts metaV1.DeleteOptions) error {
+	return r.client.Delete(ctx, name, opts)
+}
+
+func (r *ingressRouter) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := r.client.Get(ctx, name, metaV1.GetOptions{})
+	if apiErrors.IsNotFound(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *ingressRouter) List(ctx context.Context, opts metaV1.ListOptions) ([]metaV1.ObjectMeta, error) {
+	ingresses, err := r.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]metaV1.ObjectMeta, 0, len(ingresses.Items))
+
+	for _, ingress := range ingresses.Items {
+		metas = append(metas, ingress.ObjectMeta)
+	}
+
+	return metas, nil
+}
+
+// gatewayRouter publishes dashboards through Gateway API HTTPRoutes attached to
+// a gateway managed outside the backend, which also terminates TLS.
+type gatewayRouter struct {
+	conf   *core.Config
+	client dynamic.ResourceInterface
+}
+
+func (r *gatewayRouter) createHTTPRouteForDashboard(dashboard *models.Dashboard) *unstructured.Unstructured {
+	host, path := dashboardAddress(r.conf, dashboard.ID)
+	parentRef := map[string]interface{}{"name": r.conf.DashboardGatewayName}
+
+	if r.conf.DashboardGatewayNamespace != "" {
+		parentRef["namespace"] = r.conf.DashboardGatewayNamespace
+	}
+
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": httpRouteResource.GroupVersion().String(),
+		"kind":       "HTTPRoute",
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{parentRef},
+			"hostnames":  []interface{}{host},
+			"rules": []interface{}{
+				map[string]interface{}{
+					"matches": []interface{}{
+						map[string]interface{}{
+							"path": map[string]interface{}{"type": "PathPrefix", "value": path},
+						},
+					},
+					"backendRefs": []interface{}{
+						map[string]interface{}{
+							"name": dashboardResourceName(dashboard.ID),
+							"port": int64(dashboardPort),
+						},
+					},
+				},
+			},
+		},
+	}}
+
+	route.SetName(dashboardResourceName(dashboard.ID))
+	route.SetLabels(dashboardLabels(dashboard.ID))
+
+	return route
+}
+
+func (r *gatewayRouter) Create(ctx context.Context, dashboard *models.Dashboard) error {
+	_, err := r.client.Create(ctx, r.createHTTPRouteForDashboard(dashboard), metaV1.CreateOptions{})
+
+	return err
+}
+
+func (r *gatewayRouter) Delete(ctx context.Context, name string, opts metaV1.DeleteOptions) error {
+	return r.client.Delete(ctx, name, opts)
+}
+
+func (r *gatewayRouter) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := r.client.Get(ctx, name, metaV1.GetOptions{})
+	if apiErrors.IsNotFound(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (r *gatewayRouter) List(ctx context.Context, opts metaV1.ListOptions) ([]metaV1.ObjectMeta, error) {
+	routes, err := r.client.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make([]metaV1.ObjectMeta, 0, len(routes.Items))
+
+	for _, route := range routes.Items {
+		metas = append(metas, metaV1.ObjectMeta{
+			Name:              route.GetName(),
+			Labels:            route.GetLabels(),
+			CreationTimestamp: route.GetCreationTimestamp(),
+		})
+	}
+
+	return metas, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNginxIngressWithSubdomains(t *testing.T) {
+	ctx := context.Background()
+	conf := &core.Config{
+		KubernetesDashboardNamespace: testNamespace,
+		DashboardIngressDomain:       "dashboards.tutorin.tech",
+		DashboardIngressStrategy:     DashboardIngressNginx,
+		DashboardIngressClassName:    "nginx",
+		DashboardIngressSubdomains:   true,
+		DashboardTLSClusterIssuer:    "letsencrypt",
+	}
+	clientSet := fake.NewSimpleClientset()
+
+	_, err := NewKubernetesDashboardBackendWithClient(conf, clientSet, nil)
+	assert.Equal(t, errors.Is(err, errWildcardTLSSecretName), true)
+
+	conf.DashboardIngressTLSSecretName = "tit-dashboard-wildcard-tls"
+
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, clientSet, nil)
+	assert.Equal(t, err, nil)
+
+	err = backend.Start(ctx, &models.Dashboard{ID: 1, UserID: 1}, &models.DashboardTemplate{Image: "tit-dashboard"})
+	assert.Equal(t, err, nil)
+
+	ingress, _ := clientSet.NetworkingV1().Ingresses(testNamespace).Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, *ingress.Spec.IngressClassName, "nginx")
+	assert.Equal(t, ingress.Annotations["nginx.ingress.kubernetes.io/proxy-read-timeout"], nginxWebsocketTimeout)
+	assert.Equal(t, ingress.Spec.Rules[0].Host, "1.dashboards.tutorin.tech")
+	assert.Equal(t, ingress.Spec.Rules[0].HTTP.Paths[0].Path, "/")
+	assert.Equal(t, ingress.Spec.TLS[0].Hosts, []string{"1.dashboards.tutorin.tech"})
+	assert.Equal(t, ingress.Spec.TLS[0].SecretName, "tit-dashboard-wildcard-tls")
+
+	_, hasIssuer := ingress.Annotations["cert-manager.io/cluster-issuer"]
+	assert.Equal(t, hasIssuer, false)
+}
+
+func TestGatewayHTTPRoute(t *testing.T) {
+	ctx := context.Background()
+	conf := &core.Config{
+		KubernetesDashboardNamespace: testNamespace,
+		DashboardIngressDomain:       "dashboards.tutorin.tech",
+		DashboardIngressStrategy:     DashboardIngressGateway,
+		DashboardGatewayName:         "public",
+		DashboardGatewayNamespace:    "gateways",
+	}
+	dynamicClient := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{httpRouteResource: "HTTPRouteList"},
+	)
+	routes := dynamicClient.Resource(httpRouteResource).Namespace(testNamespace)
+	clientSet := fake.NewSimpleClientset()
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, clientSet, dynamicClient)
+	assert.Equal(t, err, nil)
+
+	err = backend.Start(ctx, &models.Dashboard{ID: 1, UserID: 1}, &models.DashboardTemplate{Image: "tit-dashboard"})
+	assert.Equal(t, err, nil)
+
+	route, err := routes.Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, route.GetLabels(), dashboardLabels(1))
+
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	assert.Equal(t, parentRefs[0], map[string]interface{}{"name": "public", "namespace": "gateways"})
+
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	assert.Equal(t, hostnames, []string{"dashboards.tutorin.tech"})
+
+	status, err := backend.Status(ctx, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status.Ingress, true)
+
+	_, err = clientSet.NetworkingV1().Ingresses(testNamespace).Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+
+	result, err := backend.Stop(ctx, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Deleted[0], "ingress")
+
+	_, err = routes.Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestInvalidIngressStrategy(t *testing.T) {
+	conf := &core.Config{DashboardIngressStrategy: "haproxy"}
+
+	_, err := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, errors.Is(err, errInvalidIngressStrategy), true)
+
+	conf.DashboardIngressStrategy = DashboardIngressGateway
+
+	_, err = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, errors.Is(err, errInvalidIngressStrategy), true)
+}
+
+func TestDashboardWithoutIngress(t *testing.T) {
+	ctx := context.Background()
+	conf := &core.Config{
+		KubernetesDashboardNamespace:        testNamespace,
+		DashboardIngressStrategy:            DashboardIngressNone,
+		DashboardIngressControllerNamespace: "kube-system",
+		DashboardProxyNamespace:             "tit",
+		DashboardProxyPodLabels:             map[string]string{"name": "tit-backend"},
+	}
+	clientSet := fake.NewSimpleClientset()
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, clientSet, nil)
+	assert.Equal(t, err, nil)
+
+	err = backend.Start(ctx, &models.Dashboard{ID: 1, UserID: 1}, &models.DashboardTemplate{Image: "tit-dashboard"})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, backend.Address(1), "tit-dashboard-1.dashboards.svc:8888")
+
+	_, err = clientSet.NetworkingV1().Ingresses(testNamespace).Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+
+	policy, _ := clientSet.NetworkingV1().NetworkPolicies(testNamespace).Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, len(policy.Spec.Ingress[0].From), 1)
+	assert.Equal(t, policy.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"], "tit")
+	assert.Equal(t, policy.Spec.Ingress[0].From[0].PodSelector.MatchLabels, conf.DashboardProxyPodLabels)
+
+	pod, _ := clientSet.CoreV1().Pods(testNamespace).Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	pod.Status = coreV1.PodStatus{
+		Phase:             coreV1.PodRunning,
+		ContainerStatuses: []coreV1.ContainerStatus{{Name: "dashboard", Ready: true}},
+	}
+	_, _ = clientSet.CoreV1().Pods(testNamespace).UpdateStatus(ctx, pod, metaV1.UpdateOptions{})
+
+	status, err := backend.Status(ctx, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status.State, DashboardStateReady)
+
+	result, err := backend.Stop(ctx, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Deleted, []string{"service", "pod", "networkpolicy", "secret"})
+}
+
+func TestDashboardWithoutIngressRequiresProxyNamespace(t *testing.T) {
+	conf := &core.Config{
+		KubernetesDashboardNamespace: testNamespace,
+		DashboardIngressStrategy:     DashboardIngressNone,
+	}
+
+	_, err := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, errors.Is(err, errInvalidIngressStrategy), true)
+}
@@ -0,0 +1,1090 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	typedNetworkingV1 "k8s.io/client-go/kubernetes/typed/networking/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	watchTools "k8s.io/client-go/tools/watch"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	dashboardPort              = 8888
+	dashboardPasswordSecretKey = "password"
+	// dashboardSecretMountPath holds the secret files, the dashboard entrypoint
+	// copies the password file from there to dashboardPasswordFile.
+	dashboardSecretMountPath = "/etc/tit-dashboard"
+	// dashboardPortName lets the service and network policy target the dashboard
+	// port without knowing the template the pod was started from.
+	dashboardPortName = "dashboard"
+)
+
+type dashboardResourceDeleter interface {
+	Delete(ctx context.Context, name string, opts metaV1.DeleteOptions) error
+}
+
+// KubernetesDashboardBackend runs every dashboard as a pod exposed through
+// its own service and ingress.
+type KubernetesDashboardBackend struct {
+	conf                  *core.Config
+	clientSet             kubernetes.Interface
+	podsClient            typedCoreV1.PodInterface
+	servicesClient        typedCoreV1.ServiceInterface
+	secretsClient         typedCoreV1.SecretInterface
+	router                dashboardRouter
+	networkPoliciesClient typedNetworkingV1.NetworkPolicyInterface
+	volumeClaimsClient    typedCoreV1.PersistentVolumeClaimInterface
+	resources             coreV1.ResourceRequirements
+	tolerations           []coreV1.Toleration
+	// homeVolumeSize is nil when dashboards run without home volumes.
+	homeVolumeSize *resource.Quantity
+	readinessProbe *coreV1.Probe
+	livenessProbe  *coreV1.Probe
+	// Security contexts are nil when the images run with their own settings.
+	podSecurityContext       *coreV1.PodSecurityContext
+	containerSecurityContext *coreV1.SecurityContext
+	// runCommand is nil when the backend has no REST config to exec into pods with.
+	runCommand podCommandRunner
+}
+
+func NewKubernetesDashboardBackend(conf *core.Config) (*KubernetesDashboardBackend, error) {
+	var (
+		clientConfig *rest.Config
+		err          error
+	)
+
+	if conf.KubernetesUseInClusterConfig {
+		clientConfig, err = rest.InClusterConfig()
+	} else {
+		clientConfig, err = clientcmd.BuildConfigFromFlags("", conf.KubernetesConfigPath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientSet, err := kubernetes.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, clientSet, dynamicClient)
+	if err != nil {
+		return nil, err
+	}
+
+	backend.runCommand = newPodCommandRunner(clientConfig, clientSet, conf.KubernetesDashboardNamespace)
+
+	return backend, nil
+}
+
+// NewKubernetesDashboardBackendWithClient validates the dashboard pod settings of conf
+// and builds the backend on top of the given clientSet. The dynamicClient is only
+// used by the gateway ingress strategy and may be nil otherwise.
+func NewKubernetesDashboardBackendWithClient(
+	conf *core.Config,
+	clientSet kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+) (*KubernetesDashboardBackend, error) {
+	resources, err := DashboardResources{
+		CPURequest:    conf.DashboardCPURequest,
+		CPULimit:      conf.DashboardCPULimit,
+		MemoryRequest: conf.DashboardMemoryRequest,
+		MemoryLimit:   conf.DashboardMemoryLimit,
+	}.ResourceRequirements()
+	if err != nil {
+		return nil, fmt.Errorf("dashboard resources: %w", err)
+	}
+
+	tolerations, err := parseTolerations(conf.DashboardTolerations)
+	if err != nil {
+		return nil, err
+	}
+
+	var homeVolumeSize *resource.Quantity
+
+	if conf.DashboardHomeVolumeSize != "" {
+		size, err := resource.ParseQuantity(conf.DashboardHomeVolumeSize)
+		if err != nil {
+			return nil, fmt.Errorf("dashboard home volume size: %w", err)
+		}
+
+		if conf.DashboardLimitPerUser > 1 {
+			return nil, fmt.Errorf("%w: the limit is %d", errSharedHomeVolume, conf.DashboardLimitPerUser)
+		}
+
+		homeVolumeSize = &size
+	}
+
+	podSecurityContext, containerSecurityContext, err := dashboardSecurityContexts(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// router stays nil when dashboards are reachable only through the API proxy.
+	var router dashboardRouter
+
+	if conf.DashboardIngressStrategy != DashboardIngressNone {
+		router, err = newDashboardRouter(conf, clientSet, dynamicClient)
+		if err != nil {
+			return nil, err
+		}
+	} else if conf.DashboardProxyNamespace == "" {
+		// The network policy would have no peers and admit every pod of the cluster.
+		return nil, fmt.Errorf("%w %q: proxy namespace is empty", errInvalidIngressStrategy, conf.DashboardIngressStrategy)
+	}
+
+	readinessProbe := dashboardProbe(conf.DashboardReadinessFailureThreshold, 0, conf)
+	livenessProbe := dashboardProbe(conf.DashboardLivenessFailureThreshold, conf.DashboardLivenessDelaySeconds, conf)
+
+	podsClient := clientSet.CoreV1().Pods(conf.KubernetesDashboardNamespace)
+	servicesClient := clientSet.CoreV1().Services(conf.KubernetesDashboardNamespace)
+	secretsClient := clientSet.CoreV1().Secrets(conf.KubernetesDashboardNamespace)
+	networkPoliciesClient := clientSet.NetworkingV1().NetworkPolicies(conf.KubernetesDashboardNamespace)
+	volumeClaimsClient := clientSet.CoreV1().PersistentVolumeClaims(conf.KubernetesDashboardNamespace)
+
+	return &KubernetesDashboardBackend{
+		conf:                     conf,
+		clientSet:                clientSet,
+		podsClient:               podsClient,
+		servicesClient:           servicesClient,
+		secretsClient:            secretsClient,
+		router:                   router,
+		networkPoliciesClient:    networkPoliciesClient,
+		volumeClaimsClient:       volumeClaimsClient,
+		resources:                resources,
+		tolerations:              tolerations,
+		homeVolumeSize:           homeVolumeSize,
+		readinessProbe:           readinessProbe,
+		livenessProbe:            livenessProbe,
+		podSecurityContext:       podSecurityContext,
+		containerSecurityContext: containerSecurityContext,
+	}, nil
+}
+
+// dashboardProbe checks that the dashboard accepts connections on its port.
+// Zero settings fall back to the Kubernetes defaults.
+func dashboardProbe(failureThreshold int, initialDelaySeconds int, conf *core.Config) *coreV1.Probe {
+	return &coreV1.Probe{
+		ProbeHandler: coreV1.ProbeHandler{
+			TCPSocket: &coreV1.TCPSocketAction{Port: intstr.FromString(dashboardPortName)},
+		},
+		InitialDelaySeconds: int32(initialDelaySeconds),
+		PeriodSeconds:       int32(conf.DashboardProbePeriodSeconds),
+		TimeoutSeconds:      int32(conf.DashboardProbeTimeoutSeconds),
+		FailureThreshold:    int32(failureThreshold),
+	}
+}
+
+// dashboardTemplatePort is the port the dashboard container of the template listens on.
+func dashboardTemplatePort(template *models.DashboardTemplate) int {
+	if template.Port == 0 {
+		return dashboardPort
+	}
+
+	return template.Port
+}
+
+func dashboardResourceName(dashboardID uint64) string {
+	return fmt.Sprintf("tit-dashboard-%d", dashboardID)
+}
+
+// parseDashboardID extracts the dashboard ID from the "app" label of a dashboard resource.
+// Pods and secrets claimed from the warm pool keep their names, so the name
+// itself cannot be relied on.
+func parseDashboardID(labels map[string]string) (uint64, bool) {
+	dashboardID, err := strconv.ParseUint(strings.TrimPrefix(labels["app"], "tit-dashboard-"), 10, 64)
+
+	return dashboardID, err == nil
+}
+
+// dashboardLabels are put on every dashboard resource. The "app" label also
+// selects the dashboard pod.
+func dashboardLabels(dashboardID uint64) map[string]string {
+	return map[string]string{
+		"tier": "dashboard",
+		"app":  dashboardResourceName(dashboardID),
+	}
+}
+
+func (k *KubernetesDashboardBackend) createPodForDashboard(
+	dashboard *models.Dashboard,
+	template *models.DashboardTemplate,
+) (*coreV1.Pod, error) {
+	labels := dashboardLabels(dashboard.ID)
+	if template.ID != 0 {
+		labels["template-id"] = strconv.FormatUint(template.ID, 10)
+	}
+
+	pod, err := k.createPod(dashboardResourceName(dashboard.ID), labels, template)
+	if err != nil || k.homeVolumeSize == nil {
+		return pod, err
+	}
+
+	homeVolumeSource := coreV1.VolumeSource{
+		PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{
+			ClaimName: dashboardHomeVolumeName(dashboard.UserID),
+		},
+	}
+
+	// The home volume takes the place of the writable home directory, if any.
+	for i := range pod.Spec.Volumes {
+		if pod.Spec.Volumes[i].Name == "home" {
+			pod.Spec.Volumes[i].VolumeSource = homeVolumeSource
+
+			return pod, nil
+		}
+	}
+
+	pod.Spec.Volumes = append(pod.Spec.Volumes, coreV1.Volume{Name: "home", VolumeSource: homeVolumeSource})
+	pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, coreV1.VolumeMount{
+		Name:      "home",
+		MountPath: k.conf.DashboardHomeMountPath,
+	})
+
+	return pod, nil
+}
+
+// createPod builds a dashboard pod from the template, reading its password from
+// the secret of the same name.
+func (k *KubernetesDashboardBackend) createPod(
+	resourceName string,
+	labels map[string]string,
+	template *models.DashboardTemplate,
+) (*coreV1.Pod, error) {
+	resources := k.resources
+
+	templateResources := DashboardResources{
+		CPURequest:    template.CPURequest,
+		CPULimit:      template.CPULimit,
+		MemoryRequest: template.MemoryRequest,
+		MemoryLimit:   template.MemoryLimit,
+	}
+	if templateResources != (DashboardResources{}) {
+		var err error
+
+		if resources, err = templateResources.ResourceRequirements(); err != nil {
+			return nil, fmt.Errorf("dashboard template resources: %w", err)
+		}
+	}
+
+	env := []coreV1.EnvVar{
+		{
+			Name: "PASSWORD",
+			ValueFrom: &coreV1.EnvVarSource{
+				SecretKeyRef: &coreV1.SecretKeySelector{
+					LocalObjectReference: coreV1.LocalObjectReference{Name: resourceName},
+					Key:                  dashboardPasswordSecretKey,
+				},
+			},
+		},
+	}
+
+	envNames := make([]string, 0, len(template.Env))
+	for name := range template.Env {
+		envNames = append(envNames, name)
+	}
+
+	sort.Strings(envNames)
+
+	for _, name := range envNames {
+		env = append(env, coreV1.EnvVar{Name: name, Value: template.Env[name]})
+	}
+
+	writableVolumes, writableMounts := dashboardWritableVolumes(k.containerSecurityContext, k.conf.DashboardHomeMountPath)
+
+	var runtimeClassName *string
+	if k.conf.DashboardRuntimeClassName != "" {
+		runtimeClassName = pointer.String(k.conf.DashboardRuntimeClassName)
+	}
+
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   resourceName,
+			Labels: labels,
+		},
+		Spec: coreV1.PodSpec{
+			TerminationGracePeriodSeconds: pointer.Int64(0),
+			NodeSelector:                  k.conf.DashboardNodeSelector,
+			Tolerations:                   k.tolerations,
+			PriorityClassName:             k.conf.DashboardPriorityClassName,
+			RuntimeClassName:              runtimeClassName,
+			// Dashboards have no business with the Kubernetes API.
+			AutomountServiceAccountToken: pointer.Bool(false),
+			SecurityContext:              k.podSecurityContext.DeepCopy(),
+			Containers: []coreV1.Container{
+				{
+					Name:            "dashboard",
+					Image:           template.Image,
+					Resources:       resources,
+					Env:             env,
+					SecurityContext: k.containerSecurityContext.DeepCopy(),
+					// The container is ready once websockify listens and restarted
+					// when it stops accepting connections.
+					ReadinessProbe: k.readinessProbe.DeepCopy(),
+					LivenessProbe:  k.livenessProbe.DeepCopy(),
+					Ports: []coreV1.ContainerPort{
+						{
+							Name:          dashboardPortName,
+							ContainerPort: int32(dashboardTemplatePort(template)),
+						},
+					},
+					VolumeMounts: append([]coreV1.VolumeMount{
+						{
+							Name:      "secret",
+							MountPath: dashboardSecretMountPath,
+							ReadOnly:  true,
+						},
+					}, writableMounts...),
+				},
+			},
+			Volumes: append([]coreV1.Volume{
+				{
+					Name: "secret",
+					VolumeSource: coreV1.VolumeSource{
+						Secret: &coreV1.SecretVolumeSource{
+							SecretName: resourceName,
+						},
+					},
+				},
+			}, writableVolumes...),
+		},
+	}, nil
+}
+
+func (k *KubernetesDashboardBackend) createSecretForDashboard(dashboard *models.Dashboard) *coreV1.Secret {
+	return k.createSecret(
+		dashboardResourceName(dashboard.ID), dashboardLabels(dashboard.ID), dashboardPasswdFile(dashboard),
+	)
+}
+
+func (k *KubernetesDashboardBackend) createSecret(
+	resourceName string,
+	labels map[string]string,
+	password string,
+) *coreV1.Secret {
+	return &coreV1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   resourceName,
+			Labels: labels,
+		},
+		Type: coreV1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			dashboardPasswordSecretKey: []byte(password),
+		},
+	}
+}
+
+func (k *KubernetesDashboardBackend) createServiceForDashboard(dashboard *models.Dashboard) *coreV1.Service {
+	resourceName := dashboardResourceName(dashboard.ID)
+
+	return &coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   resourceName,
+			Labels: dashboardLabels(dashboard.ID),
+		},
+		Spec: coreV1.ServiceSpec{
+			Ports: []coreV1.ServicePort{
+				{
+					Port:       dashboardPort,
+					TargetPort: intstr.FromString(dashboardPortName),
+				},
+			},
+			Selector: map[string]string{
+				"app": resourceName,
+			},
+		},
+	}
+}
+
+// egressPeers turns the configured egress allow-list into network policy peers,
+// resolving host names to their current addresses.
+func (k *KubernetesDashboardBackend) egressPeers(ctx context.Context) ([]networkingV1.NetworkPolicyPeer, error) {
+	peers := make([]networkingV1.NetworkPolicyPeer, 0, len(k.conf.DashboardEgressAllowList))
+
+	for _, item := range k.conf.DashboardEgressAllowList {
+		var cidrs []string
+
+		if _, _, err := net.ParseCIDR(item); err == nil {
+			cidrs = append(cidrs, item)
+		} else if ip := net.ParseIP(item); ip != nil {
+			cidrs = append(cidrs, hostCIDR(ip))
+		} else {
+			addresses, err := net.DefaultResolver.LookupIPAddr(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, address := range addresses {
+				cidrs = append(cidrs, hostCIDR(address.IP))
+			}
+		}
+
+		for _, cidr := range cidrs {
+			peers = append(peers, networkingV1.NetworkPolicyPeer{
+				IPBlock: &networkingV1.IPBlock{CIDR: cidr},
+			})
+		}
+	}
+
+	return peers, nil
+}
+
+func hostCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+
+	return ip.String() + "/128"
+}
+
+// namespacePeer selects the pods with podLabels in the namespace, or all its pods
+// when podLabels are empty.
+func namespacePeer(namespace string, podLabels map[string]string) networkingV1.NetworkPolicyPeer {
+	peer := networkingV1.NetworkPolicyPeer{
+		NamespaceSelector: &metaV1.LabelSelector{
+			MatchLabels: map[string]string{
+				"kubernetes.io/metadata.name": namespace,
+			},
+		},
+	}
+
+	if len(podLabels) != 0 {
+		peer.PodSelector = &metaV1.LabelSelector{MatchLabels: podLabels}
+	}
+
+	return peer
+}
+
+// createNetworkPolicyForDashboard isolates the dashboard pod: only the ingress controller
+// and the API server proxy may reach the dashboard port and egress is limited to the
+// configured allow-list.
+func (k *KubernetesDashboardBackend) createNetworkPolicyForDashboard(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+) (*networkingV1.NetworkPolicy, error) {
+	resourceName := dashboardResourceName(dashboard.ID)
+	port := intstr.FromString(dashboardPortName)
+	protocol := coreV1.ProtocolTCP
+
+	egressPeers, err := k.egressPeers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	egress := make([]networkingV1.NetworkPolicyEgressRule, 0, 1)
+	if len(egressPeers) != 0 {
+		egress = append(egress, networkingV1.NetworkPolicyEgressRule{To: egressPeers})
+	}
+
+	ingressPeers := make([]networkingV1.NetworkPolicyPeer, 0, 2)
+
+	if k.router != nil {
+		ingressPeers = append(ingressPeers, namespacePeer(
+			k.conf.DashboardIngressControllerNamespace, k.conf.DashboardIngressControllerPodLabels,
+		))
+	}
+
+	if k.conf.DashboardProxyNamespace != "" {
+		ingressPeers = append(ingressPeers, namespacePeer(
+			k.conf.DashboardProxyNamespace, k.conf.DashboardProxyPodLabels,
+		))
+	}
+
+	return &networkingV1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   resourceName,
+			Labels: dashboardLabels(dashboard.ID),
+		},
+		Spec: networkingV1.NetworkPolicySpec{
+			PodSelector: metaV1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": resourceName,
+				},
+			},
+			PolicyTypes: []networkingV1.PolicyType{
+				networkingV1.PolicyTypeIngress,
+				networkingV1.PolicyTypeEgress,
+			},
+			Ingress: []networkingV1.NetworkPolicyIngressRule{
+				{
+					From: ingressPeers,
+					Ports: []networkingV1.NetworkPolicyPort{
+						{Protocol: &protocol, Port: &port},
+					},
+				},
+			},
+			Egress: egress,
+		},
+	}, nil
+}
+
+// dashboardResourceStep creates or deletes one kind of the dashboard resources.
+type dashboardResourceStep struct {
+	kind    string
+	deleter dashboardResourceDeleter
+	create  func(ctx context.Context, dashboard *models.Dashboard, template *models.DashboardTemplate) error
+}
+
+// resourceSteps lists the dashboard resources in the order of their creation,
+// so the pod never runs without its secret and network policy.
+func (k *KubernetesDashboardBackend) resourceSteps() []dashboardResourceStep {
+	steps := []dashboardResourceStep{
+		{
+			"secret",
+			k.secretsClient,
+			func(ctx context.Context, dashboard *models.Dashboard, _ *models.DashboardTemplate) error {
+				_, err := k.secretsClient.Create(ctx, k.createSecretForDashboard(dashboard), metaV1.CreateOptions{})
+
+				return err
+			},
+		},
+		{
+			"networkpolicy",
+			k.networkPoliciesClient,
+			func(ctx context.Context, dashboard *models.Dashboard, _ *models.DashboardTemplate) error {
+				networkPolicy, err := k.createNetworkPolicyForDashboard(ctx, dashboard)
+				if err != nil {
+					return err
+				}
+
+				_, err = k.networkPoliciesClient.Create(ctx, networkPolicy, metaV1.CreateOptions{})
+
+				return err
+			},
+		},
+		{
+			"pod",
+			k.podsClient,
+			func(ctx context.Context, dashboard *models.Dashboard, template *models.DashboardTemplate) error {
+				pod, err := k.createPodForDashboard(dashboard, template)
+				if err != nil {
+					return err
+				}
+
+				_, err = k.podsClient.Create(ctx, pod, metaV1.CreateOptions{})
+
+				return err
+			},
+		},
+		{
+			"service",
+			k.servicesClient,
+			func(ctx context.Context, dashboard *models.Dashboard, _ *models.DashboardTemplate) error {
+				_, err := k.servicesClient.Create(ctx, k.createServiceForDashboard(dashboard), metaV1.CreateOptions{})
+
+				return err
+			},
+		},
+	}
+
+	if k.router != nil {
+		steps = append(steps, dashboardResourceStep{
+			"ingress",
+			k.router,
+			func(ctx context.Context, dashboard *models.Dashboard, _ *models.DashboardTemplate) error {
+				return k.router.Create(ctx, dashboard)
+			},
+		})
+	}
+
+	return steps
+}
+
+// Start creates the dashboard resources one by one. When any of them fails,
+// the resources created so far are removed. For the default template, a ready
+// pod of the warm pool is claimed instead of creating a new pod, replacing
+// dashboard.Password with the password the pod already runs with.
+func (k *KubernetesDashboardBackend) Start(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+	template *models.DashboardTemplate,
+) error {
+	resourceName := dashboardResourceName(dashboard.ID)
+	steps := k.resourceSteps()
+	claimed := false
+
+	if err := k.ensureHomeVolume(ctx, dashboard.UserID); err != nil {
+		return err
+	}
+
+	if template.ID == 0 {
+		var err error
+
+		if claimed, err = k.claimPoolPod(ctx, dashboard); err != nil {
+			return err
+		}
+	}
+
+	if claimed {
+		steps = withoutSteps(steps, "secret", "networkpolicy", "pod")
+	}
+
+	for i, step := range steps {
+		if err := step.create(ctx, dashboard, template); err != nil {
+			if claimed {
+				_, _ = k.Stop(ctx, dashboard.ID)
+
+				return err
+			}
+
+			for j := i - 1; j >= 0; j-- {
+				_ = steps[j].deleter.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func withoutSteps(steps []dashboardResourceStep, kinds ...string) []dashboardResourceStep {
+	skipped := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		skipped[kind] = true
+	}
+
+	filtered := make([]dashboardResourceStep, 0, len(steps))
+
+	for _, step := range steps {
+		if !skipped[step.kind] {
+			filtered = append(filtered, step)
+		}
+	}
+
+	return filtered
+}
+
+// findPod returns the pod of the dashboard or nil when there is none.
+func (k *KubernetesDashboardBackend) findPod(ctx context.Context, dashboardID uint64) (*coreV1.Pod, error) {
+	pods, err := k.podsClient.List(ctx, metaV1.ListOptions{LabelSelector: dashboardSelector(dashboardID)})
+	if err != nil || len(pods.Items) == 0 {
+		return nil, err
+	}
+
+	return &pods.Items[0], nil
+}
+
+// findSecret returns the secret of the dashboard or nil when there is none.
+func (k *KubernetesDashboardBackend) findSecret(ctx context.Context, dashboardID uint64) (*coreV1.Secret, error) {
+	secrets, err := k.secretsClient.List(ctx, metaV1.ListOptions{LabelSelector: dashboardSelector(dashboardID)})
+	if err != nil || len(secrets.Items) == 0 {
+		return nil, err
+	}
+
+	return &secrets.Items[0], nil
+}
+
+func dashboardSelector(dashboardID uint64) string {
+	return "tier=dashboard,app=" + dashboardResourceName(dashboardID)
+}
+
+// Stop removes the dashboard resources in the reverse order of their creation.
+// Resources that are already gone are skipped.
+func (k *KubernetesDashboardBackend) Stop(ctx context.Context, dashboardID uint64) (*DashboardStopResult, error) {
+	result := &DashboardStopResult{Deleted: []string{}}
+	steps := k.resourceSteps()
+	names := make(map[string]string, len(steps))
+
+	for _, step := range steps {
+		names[step.kind] = dashboardResourceName(dashboardID)
+	}
+
+	pod, err := k.findPod(ctx, dashboardID)
+	if err != nil {
+		return result, err
+	}
+
+	if pod != nil {
+		names["pod"] = pod.Name
+	}
+
+	secret, err := k.findSecret(ctx, dashboardID)
+	if err != nil {
+		return result, err
+	}
+
+	if secret != nil {
+		names["secret"] = secret.Name
+	}
+
+	for i := len(steps) - 1; i >= 0; i-- {
+		err := steps[i].deleter.Delete(ctx, names[steps[i].kind], metaV1.DeleteOptions{})
+		if apiErrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return result, err
+		}
+
+		result.Deleted = append(result.Deleted, steps[i].kind)
+	}
+
+	return result, nil
+}
+
+// List returns every tier=dashboard pod, the pods of the warm pool included.
+func (k *KubernetesDashboardBackend) List(ctx context.Context) ([]DashboardInstance, error) {
+	pods, err := k.podsClient.List(ctx, metaV1.ListOptions{LabelSelector: "tier=dashboard"})
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]DashboardInstance, 0, len(pods.Items))
+
+	for _, pod := range pods.Items {
+		dashboardID, _ := parseDashboardID(pod.Labels)
+
+		instances = append(instances, DashboardInstance{
+			Name:        pod.Name,
+			DashboardID: dashboardID,
+			Phase:       string(pod.Status.Phase),
+			Node:        pod.Spec.NodeName,
+			CreatedAt:   pod.CreationTimestamp.Time,
+		})
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].CreatedAt.Before(instances[j].CreatedAt)
+	})
+
+	return instances, nil
+}
+
+// Address is the cluster DNS name of the dashboard service, so proxying requires
+// the API server to run inside the cluster.
+func (k *KubernetesDashboardBackend) Address(dashboardID uint64) string {
+	return fmt.Sprintf(
+		"%s.%s.svc:%d", dashboardResourceName(dashboardID), k.conf.KubernetesDashboardNamespace, dashboardPort,
+	)
+}
+
+// UpdatePassword replaces the password file in the dashboard secret, which the
+// dashboard starts with after a restart, and overwrites the copy which the running
+// x11vnc re-reads, so that the password applies at once.
+func (k *KubernetesDashboardBackend) UpdatePassword(ctx context.Context, dashboard *models.Dashboard) error {
+	secret, err := k.findSecret(ctx, dashboard.ID)
+	if err != nil {
+		return err
+	}
+
+	if secret == nil {
+		return ErrDashboardNotRunning
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	passwdFile := dashboardPasswdFile(dashboard)
+	secret.Data[dashboardPasswordSecretKey] = []byte(passwdFile)
+
+	if _, err := k.secretsClient.Update(ctx, secret, metaV1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	if k.runCommand == nil {
+		return nil
+	}
+
+	pod, err := k.findPod(ctx, dashboard.ID)
+	if err != nil {
+		return err
+	}
+
+	if pod == nil {
+		return ErrDashboardNotRunning
+	}
+
+	// The password is passed through stdin to keep it out of the process list.
+	return k.runCommand(ctx, pod.Name, strings.NewReader(passwdFile+"\n"),
+		"sh", "-c", fmt.Sprintf("cat > %[1]s.new && mv %[1]s.new %[1]s", dashboardPasswordFile),
+	)
+}
+
+// Status combines the state of the dashboard pod, service and ingress.
+func (k *KubernetesDashboardBackend) Status(ctx context.Context, dashboardID uint64) (*DashboardStatus, error) {
+	resourceName := dashboardResourceName(dashboardID)
+	status := new(DashboardStatus)
+
+	pod, err := k.findPod(ctx, dashboardID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = k.servicesClient.Get(ctx, resourceName, metaV1.GetOptions{})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	status.Service = err == nil
+
+	if k.router != nil {
+		status.Ingress, err = k.router.Exists(ctx, resourceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pod != nil {
+		status.Pod = true
+		status.PodPhase = string(pod.Status.Phase)
+		status.TemplateID, _ = strconv.ParseUint(pod.Labels["template-id"], 10, 64)
+
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if containerStatus.Name == "dashboard" {
+				status.Restarts = containerStatus.RestartCount
+			}
+		}
+	}
+
+	status.State, status.Reason = dashboardState(pod, status, k.router != nil)
+
+	return status, nil
+}
+
+// dashboardResourceSet groups the resources of a dashboard found in the cluster.
+type dashboardResourceSet struct {
+	pod   *coreV1.Pod
+	kinds map[string]bool
+	// newest is the creation time of the most recently created resource.
+	newest time.Time
+}
+
+// listResources collects every tier=dashboard resource by the dashboard it belongs to.
+func (k *KubernetesDashboardBackend) listResources(ctx context.Context) (map[uint64]*dashboardResourceSet, error) {
+	sets := make(map[uint64]*dashboardResourceSet)
+	listOptions := metaV1.ListOptions{LabelSelector: "tier=dashboard"}
+
+	add := func(kind string, meta metaV1.ObjectMeta) *dashboardResourceSet {
+		dashboardID, ok := parseDashboardID(meta.Labels)
+		if !ok {
+			return nil
+		}
+
+		set, ok := sets[dashboardID]
+		if !ok {
+			set = &dashboardResourceSet{kinds: make(map[string]bool)}
+			sets[dashboardID] = set
+		}
+
+		set.kinds[kind] = true
+		if meta.CreationTimestamp.After(set.newest) {
+			set.newest = meta.CreationTimestamp.Time
+		}
+
+		return set
+	}
+
+	secrets, err := k.secretsClient.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range secrets.Items {
+		add("secret", secret.ObjectMeta)
+	}
+
+	networkPolicies, err := k.networkPoliciesClient.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, networkPolicy := range networkPolicies.Items {
+		add("networkpolicy", networkPolicy.ObjectMeta)
+	}
+
+	pods, err := k.podsClient.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pods.Items {
+		if set := add("pod", pods.Items[i].ObjectMeta); set != nil {
+			set.pod = &pods.Items[i]
+		}
+	}
+
+	services, err := k.servicesClient.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range services.Items {
+		add("service", service.ObjectMeta)
+	}
+
+	if k.router == nil {
+		return sets, nil
+	}
+
+	ingresses, err := k.router.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ingress := range ingresses {
+		add("ingress", ingress)
+	}
+
+	return sets, nil
+}
+
+// restoreMissing recreates the resources of a live dashboard pod absent from kinds.
+// None of them depends on the dashboard template.
+func (k *KubernetesDashboardBackend) restoreMissing(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+	kinds map[string]bool,
+) ([]string, error) {
+	restored := make([]string, 0)
+
+	for _, step := range k.resourceSteps() {
+		if kinds[step.kind] || step.kind == "pod" {
+			continue
+		}
+
+		if err := step.create(ctx, dashboard, nil); err != nil {
+			return restored, err
+		}
+
+		restored = append(restored, step.kind)
+	}
+
+	return restored, nil
+}
+
+// WaitReady watches the dashboard pod until its container becomes ready.
+func (k *KubernetesDashboardBackend) WaitReady(ctx context.Context, dashboardID uint64) error {
+	watcher, err := k.podsClient.Watch(ctx, metaV1.ListOptions{LabelSelector: dashboardSelector(dashboardID)})
+	if err != nil {
+		return err
+	}
+
+	_, err = watchTools.UntilWithoutRetry(ctx, watcher, isDashboardPodReady)
+
+	return err
+}
+
+var failedContainerReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// dashboardState derives the state of the dashboard from its resources. The ingress
+// is only expected when the backend publishes dashboards outside the cluster.
+func dashboardState(pod *coreV1.Pod, status *DashboardStatus, ingressRequired bool) (DashboardState, string) {
+	if !status.Pod {
+		if status.Service || status.Ingress {
+			return DashboardStateFailed, "dashboard pod is missing"
+		}
+
+		return DashboardStateStopped, ""
+	}
+
+	if pod.DeletionTimestamp != nil {
+		return DashboardStateTerminating, ""
+	}
+
+	ready, failureReason := dashboardPodReadiness(pod)
+	if failureReason != "" {
+		return DashboardStateFailed, failureReason
+	}
+
+	status.Ready = ready
+
+	if !status.Service || (ingressRequired && !status.Ingress) {
+		return DashboardStateProvisioning, "dashboard service or ingress is missing"
+	}
+
+	if !ready && pod.Status.Phase == coreV1.PodRunning {
+		return DashboardStateProvisioning, "dashboard is not accepting connections yet"
+	}
+
+	if !ready {
+		return DashboardStateProvisioning, ""
+	}
+
+	return DashboardStateReady, ""
+}
+
+// dashboardPodReadiness reports whether the dashboard container accepts connections,
+// or the reason why the pod is never going to.
+func dashboardPodReadiness(pod *coreV1.Pod) (bool, string) {
+	switch pod.Status.Phase {
+	case coreV1.PodFailed, coreV1.PodSucceeded:
+		if pod.Status.Reason != "" {
+			return false, pod.Status.Reason
+		}
+
+		return false, fmt.Sprintf("pod phase is %s", pod.Status.Phase)
+	case coreV1.PodPending, coreV1.PodRunning, coreV1.PodUnknown:
+	}
+
+	ready := false
+
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if waiting := containerStatus.State.Waiting; waiting != nil && failedContainerReasons[waiting.Reason] {
+			return false, waiting.Reason
+		}
+
+		if containerStatus.Name == "dashboard" {
+			ready = containerStatus.Ready
+		}
+	}
+
+	return ready && pod.Status.Phase == coreV1.PodRunning, ""
+}
+
+func isDashboardPodReady(event watch.Event) (bool, error) {
+	switch event.Type {
+	case watch.Deleted:
+		return false, fmt.Errorf("%w: dashboard pod was deleted", ErrDashboardFailed)
+	case watch.Error:
+		return false, apiErrors.FromObject(event.Object)
+	case watch.Added, watch.Modified, watch.Bookmark:
+	}
+
+	pod, ok := event.Object.(*coreV1.Pod)
+	if !ok {
+		return false, nil
+	}
+
+	ready, failureReason := dashboardPodReadiness(pod)
+	if failureReason != "" {
+		return false, fmt.Errorf("%w: %s", ErrDashboardFailed, failureReason)
+	}
+
+	return ready, nil
+}
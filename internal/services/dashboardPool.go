@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	networkingV1 "k8s.io/api/networking/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// dashboardPoolSelector selects the unassigned pods of the warm pool and their secrets.
+	dashboardPoolSelector = "tier=dashboard,pool=warm"
+	// dashboardPoolNetworkPolicyName isolates the unassigned pods until they are claimed.
+	dashboardPoolNetworkPolicyName = "tit-dashboard-pool"
+)
+
+func dashboardPoolLabels(resourceName string) map[string]string {
+	return map[string]string{
+		"tier": "dashboard",
+		"pool": "warm",
+		"app":  resourceName,
+	}
+}
+
+// claimPoolPod assigns a ready pod of the warm pool to the dashboard by relabeling
+// it and its secret, so that the network policy and service of the dashboard select it.
+// The network policy of the dashboard is created before, so the pod is never left
+// without one. It reports false when the pool is disabled or has no ready pods.
+func (k *KubernetesDashboardBackend) claimPoolPod(ctx context.Context, dashboard *models.Dashboard) (bool, error) {
+	if !k.warmPoolEnabled() {
+		return false, nil
+	}
+
+	pods, err := k.podsClient.List(ctx, metaV1.ListOptions{LabelSelector: dashboardPoolSelector})
+	if err != nil {
+		return false, err
+	}
+
+	claimed, hasNetworkPolicy := false, false
+
+	defer func() {
+		if hasNetworkPolicy && !claimed {
+			_ = k.networkPoliciesClient.Delete(ctx, dashboardResourceName(dashboard.ID), metaV1.DeleteOptions{})
+		}
+	}()
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+
+		if ready, _ := dashboardPodReadiness(pod); !ready || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		secret, err := k.secretsClient.Get(ctx, pod.Name, metaV1.GetOptions{})
+		if apiErrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		if !hasNetworkPolicy {
+			networkPolicy, err := k.createNetworkPolicyForDashboard(ctx, dashboard)
+			if err != nil {
+				return false, err
+			}
+
+			if _, err := k.networkPoliciesClient.Create(ctx, networkPolicy, metaV1.CreateOptions{}); err != nil {
+				return false, err
+			}
+
+			hasNetworkPolicy = true
+		}
+
+		// The resource version of the listed pod makes concurrent claims of it conflict.
+		pod.Labels = dashboardLabels(dashboard.ID)
+
+		_, err = k.podsClient.Update(ctx, pod, metaV1.UpdateOptions{})
+		if apiErrors.IsConflict(err) || apiErrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		secret.Labels = dashboardLabels(dashboard.ID)
+
+		if _, err := k.secretsClient.Update(ctx, secret, metaV1.UpdateOptions{}); err != nil {
+			_ = k.podsClient.Delete(ctx, pod.Name, metaV1.DeleteOptions{})
+
+			return false, err
+		}
+
+		dashboard.Password = string(secret.Data[dashboardPasswordSecretKey])
+		claimed = true
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// refillPool brings the number of healthy pods in the warm pool to the configured
+// size: failed pods are replaced and surplus ones, e.g. after the size was lowered,
+// are removed together with secrets left without pods.
+func (k *KubernetesDashboardBackend) refillPool(ctx context.Context) error {
+	if err := k.ensurePoolNetworkPolicy(ctx); err != nil {
+		return err
+	}
+
+	pods, err := k.podsClient.List(ctx, metaV1.ListOptions{LabelSelector: dashboardPoolSelector})
+	if err != nil {
+		return err
+	}
+
+	podNames := make(map[string]bool, len(pods.Items))
+	healthy := make([]string, 0, len(pods.Items))
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		podNames[pod.Name] = true
+
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		if _, failureReason := dashboardPodReadiness(pod); failureReason != "" {
+			if err := k.deletePoolDashboard(ctx, pod.Name); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		healthy = append(healthy, pod.Name)
+	}
+
+	secrets, err := k.secretsClient.List(ctx, metaV1.ListOptions{LabelSelector: dashboardPoolSelector})
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets.Items {
+		// Secrets are created before their pods, young ones might be mid-creation.
+		if podNames[secret.Name] || time.Since(secret.CreationTimestamp.Time) < reconcileGracePeriod {
+			continue
+		}
+
+		err := k.secretsClient.Delete(ctx, secret.Name, metaV1.DeleteOptions{})
+		if err != nil && !apiErrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	for i := k.conf.DashboardWarmPoolSize; i < len(healthy); i++ {
+		if err := k.deletePoolDashboard(ctx, healthy[i]); err != nil {
+			return err
+		}
+	}
+
+	for i := len(healthy); i < k.conf.DashboardWarmPoolSize; i++ {
+		if err := k.createPoolDashboard(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// warmPoolEnabled reports whether pool pods can be claimed. Pool pods are started
+// before their user is known, so they cannot mount the home volume of the user.
+func (k *KubernetesDashboardBackend) warmPoolEnabled() bool {
+	return k.conf.DashboardWarmPoolSize > 0 && k.homeVolumeSize == nil
+}
+
+// ensurePoolNetworkPolicy denies all traffic of the unassigned pods of the warm
+// pool, claimed pods leave it with their pool label.
+func (k *KubernetesDashboardBackend) ensurePoolNetworkPolicy(ctx context.Context) error {
+	networkPolicy := &networkingV1.NetworkPolicy{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: dashboardPoolNetworkPolicyName,
+			// Without the "app" label the policy is not taken for a dashboard resource.
+			Labels: map[string]string{
+				"tier": "dashboard",
+				"pool": "warm",
+			},
+		},
+		Spec: networkingV1.NetworkPolicySpec{
+			PodSelector: metaV1.LabelSelector{
+				MatchLabels: map[string]string{
+					"tier": "dashboard",
+					"pool": "warm",
+				},
+			},
+			PolicyTypes: []networkingV1.PolicyType{
+				networkingV1.PolicyTypeIngress,
+				networkingV1.PolicyTypeEgress,
+			},
+		},
+	}
+
+	_, err := k.networkPoliciesClient.Create(ctx, networkPolicy, metaV1.CreateOptions{})
+	if err != nil && !apiErrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (k *KubernetesDashboardBackend) createPoolDashboard(ctx context.Context) error {
+	resourceName := "tit-dashboard-pool-" + uuid.New().String()[:8]
+	labels := dashboardPoolLabels(resourceName)
+	secret := k.createSecret(resourceName, labels, uuid.New().String())
+
+	if _, err := k.secretsClient.Create(ctx, secret, metaV1.CreateOptions{}); err != nil {
+		return err
+	}
+
+	pod, err := k.createPod(resourceName, labels, &models.DashboardTemplate{Image: k.conf.DashboardImage})
+	if err == nil {
+		_, err = k.podsClient.Create(ctx, pod, metaV1.CreateOptions{})
+	}
+
+	if err != nil {
+		_ = k.secretsClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+	}
+
+	return err
+}
+
+func (k *KubernetesDashboardBackend) deletePoolDashboard(ctx context.Context, resourceName string) error {
+	err := k.podsClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return err
+	}
+
+	err = k.secretsClient.Delete(ctx, resourceName, metaV1.DeleteOptions{})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// DashboardWarmPool keeps a number of unassigned dashboard pods running, so
+// starting a dashboard does not wait for the dashboard image to boot.
+type DashboardWarmPool struct {
+	dashboardService *DashboardService
+	logger           *core.Logger
+	interval         time.Duration
+}
+
+func NewDashboardWarmPool(
+	dashboardService *DashboardService,
+	logger *core.Logger,
+	conf *core.Config,
+) *DashboardWarmPool {
+	return &DashboardWarmPool{
+		dashboardService: dashboardService,
+		logger:           logger,
+		interval:         time.Duration(conf.DashboardWarmPoolIntervalSeconds) * time.Second,
+	}
+}
+
+// Run blocks until ctx is done, refilling the pool right away and then every interval.
+func (p *DashboardWarmPool) Run(ctx context.Context) {
+	backend, ok := p.dashboardService.backend.(*KubernetesDashboardBackend)
+	if !ok || !backend.warmPoolEnabled() || p.interval <= 0 {
+		p.logger.Info().Msg("Dashboard warm pool is disabled")
+
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := backend.refillPool(ctx); err != nil {
+			p.logger.Err(err).Msg("dashboard warm pool refilling")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
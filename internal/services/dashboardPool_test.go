@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateDashboardClaimsWarmPoolPod(t *testing.T) {
+	ctx := context.Background()
+	dashboardService, clientSet, mock := setupDashboardService()
+	backend, _ := dashboardService.backend.(*KubernetesDashboardBackend)
+	backend.conf.DashboardWarmPoolSize = 2
+	pods := clientSet.CoreV1().Pods(testNamespace)
+
+	err := backend.refillPool(ctx)
+	assert.Equal(t, err, nil)
+
+	poolPods, _ := pods.List(ctx, metaV1.ListOptions{LabelSelector: dashboardPoolSelector})
+	assert.Equal(t, len(poolPods.Items), 2)
+
+	networkPolicies := clientSet.NetworkingV1().NetworkPolicies(testNamespace)
+	poolPolicy, err := networkPolicies.Get(ctx, dashboardPoolNetworkPolicyName, metaV1.GetOptions{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, poolPolicy.Spec.PodSelector.MatchLabels, map[string]string{"tier": "dashboard", "pool": "warm"})
+	assert.Equal(t, len(poolPolicy.Spec.Ingress), 0)
+	assert.Equal(t, len(poolPolicy.Spec.Egress), 0)
+
+	readyPod := poolPods.Items[0]
+	readyPod.Status = coreV1.PodStatus{
+		Phase:             coreV1.PodRunning,
+		ContainerStatuses: []coreV1.ContainerStatus{{Name: "dashboard", Ready: true}},
+	}
+	_, _ = pods.UpdateStatus(ctx, &readyPod, metaV1.UpdateOptions{})
+
+	expectDashboardInsert(mock)
+	expectDashboardRunning(mock)
+	mock.ExpectCommit()
+
+	dashboard, err := dashboardService.CreateDashboard(ctx, &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+
+	claimed, _ := pods.Get(ctx, readyPod.Name, metaV1.GetOptions{})
+	assert.Equal(t, claimed.Labels, dashboardLabels(1))
+
+	_, err = networkPolicies.Get(ctx, dashboardResourceName(1), metaV1.GetOptions{})
+	assert.Equal(t, err, nil)
+
+	secret, _ := clientSet.CoreV1().Secrets(testNamespace).Get(ctx, readyPod.Name, metaV1.GetOptions{})
+	assert.Equal(t, string(secret.Data["password"]), dashboard.Password)
+
+	_, err = pods.Get(ctx, dashboardResourceName(1), metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+
+	status, err := backend.Status(ctx, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status.State, DashboardStateReady)
+
+	err = backend.refillPool(ctx)
+	assert.Equal(t, err, nil)
+
+	poolPods, _ = pods.List(ctx, metaV1.ListOptions{LabelSelector: dashboardPoolSelector})
+	assert.Equal(t, len(poolPods.Items), 2)
+
+	result, err := backend.Stop(ctx, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Deleted, []string{"ingress", "service", "pod", "networkpolicy", "secret"})
+
+	_, err = pods.Get(ctx, readyPod.Name, metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+}
+
+func TestCreateDashboardWithoutReadyWarmPoolPod(t *testing.T) {
+	ctx := context.Background()
+	dashboardService, clientSet, mock := setupDashboardService()
+	backend, _ := dashboardService.backend.(*KubernetesDashboardBackend)
+	backend.conf.DashboardWarmPoolSize = 1
+
+	_ = backend.refillPool(ctx)
+
+	expectDashboardInsert(mock)
+	expectDashboardRunning(mock)
+	mock.ExpectCommit()
+
+	_, err := dashboardService.CreateDashboard(ctx, &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, err, nil)
+	assertDashboardResources(t, clientSet, true)
+}
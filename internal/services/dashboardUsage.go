@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+var ErrDashboardQuotaExceeded = errors.New("monthly dashboard quota exceeded")
+
+// DashboardUsageReport sums the time the dashboards of a user ran within a period.
+// QuotaHours is nil when the user has no quota.
+type DashboardUsageReport struct {
+	UserID     uint64    `json:"userId"`
+	Email      string    `json:"email,omitempty"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Hours      float64   `json:"hours"`
+	QuotaHours *int      `json:"quotaHours,omitempty"`
+}
+
+type dashboardUsageRow struct {
+	UserID              uint64  `bun:"user_id"`
+	Email               string  `bun:"email"`
+	DashboardQuotaHours *int    `bun:"dashboard_quota_hours"`
+	Seconds             float64 `bun:"seconds"`
+}
+
+// DashboardUsageMonth returns the calendar month, in UTC, which t falls within.
+func DashboardUsageMonth(t time.Time) (time.Time, time.Time) {
+	from := time.Date(t.UTC().Year(), t.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	return from, from.AddDate(0, 1, 0)
+}
+
+// dashboardQuotaHours is the monthly quota of the user, zero means unlimited.
+func (d *DashboardService) dashboardQuotaHours(user *models.User) int {
+	if user.DashboardQuotaHours != nil {
+		return *user.DashboardQuotaHours
+	}
+
+	return d.conf.DashboardMonthlyQuotaHours
+}
+
+// recordUsageStart opens the usage interval of the started dashboard.
+func (d *DashboardService) recordUsageStart(ctx context.Context, dashboard *models.Dashboard) error {
+	_, err := d.db.NewInsert().
+		Model(&models.DashboardUsage{
+			DashboardID: dashboard.ID,
+			UserID:      dashboard.UserID,
+			StartedAt:   time.Now(),
+		}).
+		Exec(ctx)
+
+	return err
+}
+
+// recordUsageStop closes the usage interval of the stopped dashboard.
+func (d *DashboardService) recordUsageStop(
+	ctx context.Context,
+	dashboard *models.Dashboard,
+	stoppedAt time.Time,
+) error {
+	_, err := d.db.NewUpdate().
+		Model((*models.DashboardUsage)(nil)).
+		Set("stopped_at = ?", stoppedAt).
+		Where("dashboard_id = ?", dashboard.ID).
+		Where("stopped_at IS NULL").
+		Exec(ctx)
+
+	return err
+}
+
+// usageRows sums the usage intervals overlapping [from, to) by user, intervals of
+// running dashboards last until now. Only the usage of userID is summed unless it is zero.
+func (d *DashboardService) usageRows(
+	ctx context.Context,
+	userID uint64,
+	from time.Time,
+	to time.Time,
+) ([]dashboardUsageRow, error) {
+	rows := make([]dashboardUsageRow, 0)
+
+	query := d.db.NewSelect().
+		Model((*models.DashboardUsage)(nil)).
+		ColumnExpr("du.user_id, u.email, u.dashboard_quota_hours").
+		ColumnExpr(
+			"SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(du.stopped_at, now()), ?) - GREATEST(du.started_at, ?))) AS seconds",
+			to, from,
+		).
+		Join("JOIN users AS u ON u.id = du.user_id").
+		Where("du.started_at < ?", to).
+		Where("du.stopped_at IS NULL OR du.stopped_at > ?", from).
+		GroupExpr("du.user_id, u.email, u.dashboard_quota_hours").
+		OrderExpr("seconds DESC")
+
+	if userID != 0 {
+		query = query.Where("du.user_id = ?", userID)
+	}
+
+	if err := query.Scan(ctx, &rows); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func (d *DashboardService) usageReport(row dashboardUsageRow, from time.Time, to time.Time) *DashboardUsageReport {
+	report := &DashboardUsageReport{
+		UserID: row.UserID,
+		Email:  row.Email,
+		From:   from,
+		To:     to,
+		Hours:  row.Seconds / time.Hour.Seconds(),
+	}
+
+	quotaHours := d.dashboardQuotaHours(&models.User{DashboardQuotaHours: row.DashboardQuotaHours})
+	if quotaHours > 0 {
+		report.QuotaHours = &quotaHours
+	}
+
+	return report
+}
+
+// GetDashboardUsage reports how long the dashboards of the user ran within [from, to).
+func (d *DashboardService) GetDashboardUsage(
+	ctx context.Context,
+	user *models.User,
+	from time.Time,
+	to time.Time,
+) (*DashboardUsageReport, error) {
+	rows, err := d.usageRows(ctx, user.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	row := dashboardUsageRow{UserID: user.ID, Email: user.Email, DashboardQuotaHours: user.DashboardQuotaHours}
+	if len(rows) != 0 {
+		row = rows[0]
+	}
+
+	return d.usageReport(row, from, to), nil
+}
+
+// ListDashboardUsage reports the usage of every user whose dashboards ran within
+// [from, to), the heaviest users first.
+func (d *DashboardService) ListDashboardUsage(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) ([]*DashboardUsageReport, error) {
+	rows, err := d.usageRows(ctx, 0, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*DashboardUsageReport, 0, len(rows))
+
+	for _, row := range rows {
+		reports = append(reports, d.usageReport(row, from, to))
+	}
+
+	return reports, nil
+}
+
+// checkDashboardQuota refuses new dashboards to users who used up their quota
+// of the current month.
+func (d *DashboardService) checkDashboardQuota(ctx context.Context, user *models.User) error {
+	quotaHours := d.dashboardQuotaHours(user)
+	if quotaHours <= 0 {
+		return nil
+	}
+
+	from, to := DashboardUsageMonth(time.Now())
+
+	usage, err := d.GetDashboardUsage(ctx, user, from, to)
+	if err != nil {
+		return err
+	}
+
+	if usage.Hours >= float64(quotaHours) {
+		return ErrDashboardQuotaExceeded
+	}
+
+	return nil
+}
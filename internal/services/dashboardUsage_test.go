@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+func expectDashboardUsage(mock sqlmock.Sqlmock, quotaHours interface{}, seconds float64) {
+	mock.ExpectQuery("SELECT du.user_id, u.email, u.dashboard_quota_hours, SUM(.+) FROM \"dashboard_usages\"").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "dashboard_quota_hours", "seconds"}).
+			AddRow(1, "user@tutorin.tech", quotaHours, seconds))
+}
+
+func TestDashboardUsageMonth(t *testing.T) {
+	from, to := DashboardUsageMonth(time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, from, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, to, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestGetDashboardUsage(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	dashboardService.conf.DashboardMonthlyQuotaHours = 20
+	from, to := DashboardUsageMonth(time.Now())
+
+	expectDashboardUsage(mock, nil, 5400)
+
+	usage, err := dashboardService.GetDashboardUsage(context.Background(), &models.User{ID: 1}, from, to)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, usage.Hours, 1.5)
+	assert.Equal(t, *usage.QuotaHours, 20)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestGetDashboardUsageWithoutDashboards(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	from, to := DashboardUsageMonth(time.Now())
+
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboard_usages\"").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email", "dashboard_quota_hours", "seconds"}))
+
+	usage, err := dashboardService.GetDashboardUsage(context.Background(), &models.User{ID: 1}, from, to)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, usage.UserID, uint64(1))
+	assert.Equal(t, usage.Hours, 0.0)
+	assert.Equal(t, usage.QuotaHours, nil)
+}
+
+func TestCreateDashboardOverQuota(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	quotaHours := 2
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(1, 1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectDashboardUsage(mock, quotaHours, 7200)
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(
+		context.Background(),
+		&models.User{ID: 1, DashboardQuotaHours: &quotaHours},
+		DashboardStartOptions{},
+	)
+	assert.Equal(t, err, ErrDashboardQuotaExceeded)
+	assertDashboardResources(t, clientSet, false)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
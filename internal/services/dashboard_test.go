@@ -0,0 +1,720 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	networkingV1 "k8s.io/api/networking/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clientTesting "k8s.io/client-go/testing"
+	"k8s.io/utils/pointer"
+)
+
+const testNamespace = "dashboards"
+
+var errTestCreate = errors.New("create failed")
+
+func setupDashboardService(objects ...runtime.Object) (*DashboardService, *fake.Clientset, sqlmock.Sqlmock) {
+	db, mock := core.NewMockDatabase()
+	conf := &core.Config{
+		KubernetesDashboardNamespace: testNamespace,
+		DashboardImage:               "tit-dashboard:latest",
+		DashboardIngressDomain:       "dashboards.tutorin.tech",
+		DashboardReadyTimeoutSeconds: 1,
+		DashboardLimitPerUser:        1,
+	}
+	clientSet := fake.NewSimpleClientset(objects...)
+	backend, _ := NewKubernetesDashboardBackendWithClient(conf, clientSet, nil)
+
+	return NewDashboardServiceWithBackend(db, conf, backend), clientSet, mock
+}
+
+func failCreating(clientSet *fake.Clientset, resource string) {
+	clientSet.PrependReactor("create", resource, func(clientTesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errTestCreate
+	})
+}
+
+func assertDashboardResources(t *testing.T, clientSet *fake.Clientset, exist bool) {
+	t.Helper()
+
+	ctx := context.Background()
+	name := dashboardResourceName(1)
+
+	_, err := clientSet.CoreV1().Pods(testNamespace).Get(ctx, name, metaV1.GetOptions{})
+	assert.Equal(t, err == nil, exist)
+
+	_, err = clientSet.CoreV1().Services(testNamespace).Get(ctx, name, metaV1.GetOptions{})
+	assert.Equal(t, err == nil, exist)
+
+	_, err = clientSet.NetworkingV1().Ingresses(testNamespace).Get(ctx, name, metaV1.GetOptions{})
+	assert.Equal(t, err == nil, exist)
+
+	_, err = clientSet.NetworkingV1().NetworkPolicies(testNamespace).Get(ctx, name, metaV1.GetOptions{})
+	assert.Equal(t, err == nil, exist)
+
+	_, err = clientSet.CoreV1().Secrets(testNamespace).Get(ctx, name, metaV1.GetOptions{})
+	assert.Equal(t, err == nil, exist)
+}
+
+func newDashboardPod(phase coreV1.PodPhase, containerStatus coreV1.ContainerStatus) *coreV1.Pod {
+	containerStatus.Name = "dashboard"
+
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: dashboardResourceName(1), Namespace: testNamespace, Labels: dashboardLabels(1)},
+		Status: coreV1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: []coreV1.ContainerStatus{containerStatus},
+		},
+	}
+}
+
+// expectDashboardInsert expects CreateDashboard to take the lock of user 1, find
+// no running dashboards of the user and save a dashboard with ID 1.
+func expectDashboardInsert(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(1, 1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("INSERT INTO \"dashboards\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+// expectDashboardRunning expects the started dashboard to be marked as running
+// and its usage interval to be opened.
+func expectDashboardRunning(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO \"dashboard_usages\"").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+// expectDashboardStopped expects the dashboard to be marked as stopped and its
+// usage interval to be closed.
+func expectDashboardStopped(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("UPDATE \"dashboards\" AS \"d\" SET status = 'stopped'").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE \"dashboard_usages\" AS \"du\" SET stopped_at").WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// startTestDashboard creates the resources of dashboard 1 bypassing the database.
+func startTestDashboard(t *testing.T, dashboardService *DashboardService) *models.Dashboard {
+	t.Helper()
+
+	dashboard := &models.Dashboard{ID: 1, UserID: 1, Password: "secret", Status: models.DashboardRunning}
+
+	err := dashboardService.backend.Start(context.Background(), dashboard, &models.DashboardTemplate{})
+	assert.Equal(t, err, nil)
+
+	return dashboard
+}
+
+func TestCreateDashboard(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+
+	expectDashboardInsert(mock)
+	expectDashboardRunning(mock)
+	mock.ExpectCommit()
+
+	dashboard, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, dashboard.ID, uint64(1))
+	assert.Equal(t, dashboard.Status, models.DashboardRunning)
+	assert.NotEqual(t, dashboard.Password, "")
+	assertDashboardResources(t, clientSet, true)
+
+	pod, _ := clientSet.CoreV1().Pods(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, pod.Spec.Containers[0].Image, "tit-dashboard:latest")
+	assert.Equal(t, pod.Spec.Containers[0].Env[0].Value, "")
+	assert.Equal(t, pod.Spec.Containers[0].Env[0].ValueFrom.SecretKeyRef.Name, "tit-dashboard-1")
+
+	secret, _ := clientSet.CoreV1().Secrets(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, string(secret.Data["password"]), dashboard.Password)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestCreateDashboardFromTutorialTemplate(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+
+	mock.ExpectQuery("SELECT (.+) FROM \"tutorials\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "dashboard_template_id"}).AddRow(2, 3))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboard_templates\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "image", "env"}).AddRow(3, "python:3", `{"LANG": "C"}`))
+	expectDashboardInsert(mock)
+	expectDashboardRunning(mock)
+	mock.ExpectCommit()
+
+	user := &models.User{ID: 1}
+
+	dashboard, err := dashboardService.CreateDashboard(context.Background(), user, DashboardStartOptions{TutorialID: 2})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *dashboard.TemplateID, uint64(3))
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+
+	pod, _ := clientSet.CoreV1().Pods(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, pod.Spec.Containers[0].Image, "python:3")
+	assert.Equal(t, pod.Spec.Containers[0].Env[1].Value, "C")
+
+	status, _ := dashboardService.GetDashboardStatus(context.Background(), dashboard)
+	assert.Equal(t, status.TemplateID, uint64(3))
+
+	mock.ExpectQuery("SELECT (.+) FROM \"tutorials\"").WillReturnError(sql.ErrNoRows)
+
+	_, err = dashboardService.CreateDashboard(context.Background(), user, DashboardStartOptions{TutorialID: 4})
+	assert.Equal(t, err, ErrTutorialNotFound)
+}
+
+func TestCreateDashboardRespectsLimit(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	// The running dashboard is of another template.
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\" (.+) AND \\(template_id IS NULL\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, err, ErrDashboardLimitReached)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, false)
+}
+
+func TestCreateDashboardReturnsExistingAtLimit(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+
+	expectDashboardInsert(mock)
+	expectDashboardRunning(mock)
+	mock.ExpectCommit()
+
+	dashboard, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, err, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(1, 1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT count\\(\\*\\) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\" (.+) AND \\(template_id IS NULL\\) ORDER BY \"id\" DESC LIMIT 1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "password"}).
+			AddRow(dashboard.ID, 1, models.DashboardRunning, dashboard.Password))
+	mock.ExpectCommit()
+
+	again, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, again.ID, dashboard.ID)
+	assert.Equal(t, again.Password, dashboard.Password)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, true)
+}
+
+func TestCreateDashboardRollsBackOnServiceFailure(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	failCreating(clientSet, "services")
+
+	expectDashboardInsert(mock)
+	mock.ExpectExec("DELETE FROM \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, errors.Is(err, errTestCreate), true)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, false)
+}
+
+func TestCreateDashboardRollsBackOnIngressFailure(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	failCreating(clientSet, "ingresses")
+
+	expectDashboardInsert(mock)
+	mock.ExpectExec("DELETE FROM \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, errors.Is(err, errTestCreate), true)
+	assertDashboardResources(t, clientSet, false)
+}
+
+func TestCreateDashboardRollsBackOnDatabaseFailure(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+
+	expectDashboardInsert(mock)
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnError(errTestCreate)
+	mock.ExpectExec("DELETE FROM \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, errors.Is(err, errTestCreate), true)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, false)
+}
+
+func TestCreateDashboardFailsWhenPodExists(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService(newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{}))
+
+	expectDashboardInsert(mock)
+	mock.ExpectExec("DELETE FROM \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, apiErrors.IsAlreadyExists(err), true)
+
+	_, err = clientSet.CoreV1().Pods(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, err, nil)
+}
+
+func TestStopDashboard(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	dashboard := startTestDashboard(t, dashboardService)
+
+	expectDashboardStopped(mock)
+
+	result, err := dashboardService.StopDashboard(context.Background(), dashboard)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result.Deleted, []string{"ingress", "service", "pod", "networkpolicy", "secret"})
+	assert.Equal(t, dashboard.Password, "")
+	assert.Equal(t, dashboard.Status, models.DashboardStopped)
+	assertDashboardResources(t, clientSet, false)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestForceStopUserDashboards(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	startTestDashboard(t, dashboardService)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock\\(1, 1\\)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(1, 1, models.DashboardRunning))
+	expectDashboardStopped(mock)
+	mock.ExpectCommit()
+
+	dashboards, err := dashboardService.ForceStopUserDashboards(context.Background(), &models.User{ID: 1})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(dashboards), 1)
+	assert.Equal(t, dashboards[0].Status, models.DashboardStopped)
+	assertDashboardResources(t, clientSet, false)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestListDashboardPods(t *testing.T) {
+	createdAt := metaV1.NewTime(time.Now().Add(-time.Hour))
+	dashboardPod := newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{})
+	dashboardPod.CreationTimestamp = createdAt
+	dashboardPod.Spec.NodeName = "node-1"
+	poolPod := &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Name:              "tit-dashboard-pool-abc",
+		Namespace:         testNamespace,
+		Labels:            map[string]string{"tier": "dashboard", "app": "tit-dashboard-pool-abc"},
+		CreationTimestamp: metaV1.NewTime(createdAt.Add(time.Minute)),
+	}}
+
+	dashboardService, _, mock := setupDashboardService(dashboardPod, poolPod)
+
+	mock.ExpectQuery("SELECT d.id AS dashboard_id, d.user_id, u.email FROM dashboards AS d JOIN users AS u").
+		WillReturnRows(sqlmock.NewRows([]string{"dashboard_id", "user_id", "email"}).AddRow(1, 1, "user@tutorin.tech"))
+
+	pods, err := dashboardService.ListDashboardPods(context.Background())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(pods), 2)
+	assert.Equal(t, pods[0].Name, "tit-dashboard-1")
+	assert.Equal(t, pods[0].OwnerEmail, "user@tutorin.tech")
+	assert.Equal(t, pods[0].Phase, string(coreV1.PodRunning))
+	assert.Equal(t, pods[0].Node, "node-1")
+	assert.Equal(t, pods[0].Age, "1h0m0s")
+	assert.Equal(t, pods[1].DashboardID, uint64(0))
+	assert.Equal(t, pods[1].OwnerEmail, "")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestRotateDashboardPassword(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+
+	err := dashboardService.RotateDashboardPassword(context.Background(), &models.Dashboard{ID: 1})
+	assert.Equal(t, err, ErrDashboardNotRunning)
+
+	dashboard := startTestDashboard(t, dashboardService)
+
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = dashboardService.RotateDashboardPassword(context.Background(), dashboard)
+	assert.Equal(t, err, nil)
+	assert.NotEqual(t, dashboard.Password, "secret")
+
+	secret, _ := clientSet.CoreV1().Secrets(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, string(secret.Data["password"]), dashboard.Password)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestDashboardStatus(t *testing.T) {
+	service := &coreV1.Service{ObjectMeta: metaV1.ObjectMeta{Name: "tit-dashboard-1", Namespace: testNamespace}}
+	ingress := &networkingV1.Ingress{ObjectMeta: metaV1.ObjectMeta{Name: "tit-dashboard-1", Namespace: testNamespace}}
+	crashLoop := coreV1.ContainerStatus{
+		State: coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		state   DashboardState
+		ready   bool
+	}{
+		{"stopped", nil, DashboardStateStopped, false},
+		{"missing pod", []runtime.Object{service, ingress}, DashboardStateFailed, false},
+		{
+			"pending pod",
+			[]runtime.Object{newDashboardPod(coreV1.PodPending, coreV1.ContainerStatus{}), service, ingress},
+			DashboardStateProvisioning,
+			false,
+		},
+		{
+			"missing ingress",
+			[]runtime.Object{newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{Ready: true}), service},
+			DashboardStateProvisioning,
+			true,
+		},
+		{
+			"not accepting connections",
+			[]runtime.Object{newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{}), service, ingress},
+			DashboardStateProvisioning,
+			false,
+		},
+		{
+			"crash loop",
+			[]runtime.Object{newDashboardPod(coreV1.PodRunning, crashLoop), service, ingress},
+			DashboardStateFailed,
+			false,
+		},
+		{
+			"ready",
+			[]runtime.Object{newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{Ready: true}), service, ingress},
+			DashboardStateReady,
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dashboardService, _, _ := setupDashboardService(test.objects...)
+
+			status, err := dashboardService.GetDashboardStatus(context.Background(), &models.Dashboard{ID: 1})
+			assert.Equal(t, err, nil)
+			assert.Equal(t, status.State, test.state)
+			assert.Equal(t, status.Ready, test.ready)
+		})
+	}
+}
+
+func TestDashboardStatusReportsRestarts(t *testing.T) {
+	service := &coreV1.Service{ObjectMeta: metaV1.ObjectMeta{Name: "tit-dashboard-1", Namespace: testNamespace}}
+	ingress := &networkingV1.Ingress{ObjectMeta: metaV1.ObjectMeta{Name: "tit-dashboard-1", Namespace: testNamespace}}
+	pod := newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{RestartCount: 2})
+
+	dashboardService, _, _ := setupDashboardService(pod, service, ingress)
+
+	status, err := dashboardService.GetDashboardStatus(context.Background(), &models.Dashboard{ID: 1})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, status.Restarts, int32(2))
+	assert.Equal(t, status.Reason, "dashboard is not accepting connections yet")
+}
+
+func TestWaitForDashboardReady(t *testing.T) {
+	dashboardService, clientSet, _ := setupDashboardService()
+
+	clientSet.PrependWatchReactor("pods", func(clientTesting.Action) (bool, watch.Interface, error) {
+		watcher := watch.NewFake()
+
+		go func() {
+			watcher.Add(newDashboardPod(coreV1.PodPending, coreV1.ContainerStatus{}))
+			watcher.Modify(newDashboardPod(coreV1.PodRunning, coreV1.ContainerStatus{Ready: true}))
+		}()
+
+		return true, watcher, nil
+	})
+
+	err := dashboardService.WaitForDashboardReady(context.Background(), &models.Dashboard{ID: 1})
+	assert.Equal(t, err, nil)
+}
+
+func TestWaitForDashboardReadyTimeout(t *testing.T) {
+	dashboardService, clientSet, _ := setupDashboardService()
+
+	clientSet.PrependWatchReactor("pods", func(clientTesting.Action) (bool, watch.Interface, error) {
+		return true, watch.NewFake(), nil
+	})
+
+	err := dashboardService.WaitForDashboardReady(context.Background(), &models.Dashboard{ID: 1})
+	assert.Equal(t, err, ErrDashboardReadyTimeout)
+}
+
+func TestNetworkPolicyForUser(t *testing.T) {
+	conf := &core.Config{
+		DashboardIngressControllerNamespace: "kube-system",
+		DashboardEgressAllowList:            []string{"10.0.0.0/8", "192.168.1.1"},
+	}
+	backend, _ := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+
+	policy, err := backend.createNetworkPolicyForDashboard(context.Background(), &models.Dashboard{ID: 1})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, policy.Spec.PodSelector.MatchLabels["app"], "tit-dashboard-1")
+	assert.Equal(t, policy.Spec.Ingress[0].Ports[0].Port.String(), dashboardPortName)
+	assert.Equal(t, policy.Spec.Egress[0].To[0].IPBlock.CIDR, "10.0.0.0/8")
+	assert.Equal(t, policy.Spec.Egress[0].To[1].IPBlock.CIDR, "192.168.1.1/32")
+
+	conf.DashboardEgressAllowList = nil
+
+	policy, _ = backend.createNetworkPolicyForDashboard(context.Background(), &models.Dashboard{ID: 1})
+	assert.Equal(t, len(policy.Spec.Egress), 0)
+}
+
+func TestDashboardPodSettings(t *testing.T) {
+	conf := &core.Config{
+		DashboardCPURequest:        "250m",
+		DashboardMemoryLimit:       "1Gi",
+		DashboardNodeSelector:      map[string]string{"pool": "dashboards"},
+		DashboardTolerations:       []string{"dedicated=dashboards:NoSchedule", "spot"},
+		DashboardPriorityClassName: "low",
+	}
+
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, err, nil)
+
+	pod, err := backend.createPodForDashboard(&models.Dashboard{ID: 1}, &models.DashboardTemplate{})
+	assert.Equal(t, err, nil)
+
+	resources := pod.Spec.Containers[0].Resources
+	assert.Equal(t, resources.Requests.Cpu().String(), "250m")
+	assert.Equal(t, resources.Limits.Memory().String(), "1Gi")
+	assert.Equal(t, pod.Spec.NodeSelector["pool"], "dashboards")
+	assert.Equal(t, pod.Spec.PriorityClassName, "low")
+	assert.Equal(t, pod.Spec.Tolerations, []coreV1.Toleration{
+		{Key: "dedicated", Operator: coreV1.TolerationOpEqual, Value: "dashboards", Effect: coreV1.TaintEffectNoSchedule},
+		{Key: "spot", Operator: coreV1.TolerationOpExists},
+	})
+
+	conf.DashboardCPULimit = "lots"
+	_, err = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.NotEqual(t, err, nil)
+
+	conf.DashboardCPULimit = ""
+	conf.DashboardTolerations = []string{"dedicated:Sometimes"}
+	_, err = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, errors.Is(err, errInvalidToleration), true)
+}
+
+func TestDashboardPodProbes(t *testing.T) {
+	conf := &core.Config{
+		DashboardProbePeriodSeconds:        5,
+		DashboardProbeTimeoutSeconds:       1,
+		DashboardReadinessFailureThreshold: 3,
+		DashboardLivenessDelaySeconds:      15,
+		DashboardLivenessFailureThreshold:  6,
+	}
+
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, err, nil)
+
+	pod, err := backend.createPodForDashboard(&models.Dashboard{ID: 1}, &models.DashboardTemplate{Port: 6080})
+	assert.Equal(t, err, nil)
+
+	handler := coreV1.ProbeHandler{TCPSocket: &coreV1.TCPSocketAction{Port: intstr.FromString("dashboard")}}
+	container := pod.Spec.Containers[0]
+	assert.Equal(t, container.ReadinessProbe, &coreV1.Probe{
+		ProbeHandler:     handler,
+		PeriodSeconds:    5,
+		TimeoutSeconds:   1,
+		FailureThreshold: 3,
+	})
+	assert.Equal(t, container.LivenessProbe, &coreV1.Probe{
+		ProbeHandler:        handler,
+		InitialDelaySeconds: 15,
+		PeriodSeconds:       5,
+		TimeoutSeconds:      1,
+		FailureThreshold:    6,
+	})
+	assert.Equal(t, container.Ports[0].Name, "dashboard")
+	assert.Equal(t, container.Ports[0].ContainerPort, int32(6080))
+}
+
+func TestDashboardPodSecurity(t *testing.T) {
+	conf := &core.Config{DashboardRuntimeClassName: "gvisor", DashboardHomeMountPath: "/home/dashboard"}
+
+	backend, err := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, err, nil)
+
+	pod, err := backend.createPodForDashboard(&models.Dashboard{ID: 1}, &models.DashboardTemplate{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, *pod.Spec.AutomountServiceAccountToken, false)
+	assert.Equal(t, *pod.Spec.RuntimeClassName, "gvisor")
+	assert.Equal(t, pod.Spec.SecurityContext, &coreV1.PodSecurityContext{
+		RunAsNonRoot:   pointer.Bool(true),
+		RunAsUser:      pointer.Int64(1000),
+		RunAsGroup:     pointer.Int64(1000),
+		FSGroup:        pointer.Int64(1000),
+		SeccompProfile: &coreV1.SeccompProfile{Type: coreV1.SeccompProfileTypeRuntimeDefault},
+	})
+	assert.Equal(t, pod.Spec.Containers[0].SecurityContext, &coreV1.SecurityContext{
+		AllowPrivilegeEscalation: pointer.Bool(false),
+		Capabilities:             &coreV1.Capabilities{Drop: []coreV1.Capability{"ALL"}},
+		ReadOnlyRootFilesystem:   pointer.Bool(true),
+	})
+	assert.Equal(t, pod.Spec.Volumes[1].Name, "home")
+	assert.NotEqual(t, pod.Spec.Volumes[1].EmptyDir, nil)
+	assert.Equal(t, pod.Spec.Volumes[2].Name, "tmp")
+	assert.NotEqual(t, pod.Spec.Volumes[2].EmptyDir, nil)
+	assert.Equal(t, pod.Spec.Containers[0].VolumeMounts[1:], []coreV1.VolumeMount{
+		{Name: "home", MountPath: conf.DashboardHomeMountPath},
+		{Name: "tmp", MountPath: "/tmp"},
+	})
+
+	conf.DashboardRunAsUser = 2000
+	backend, _ = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	pod, _ = backend.createPodForDashboard(&models.Dashboard{ID: 1}, &models.DashboardTemplate{})
+	assert.Equal(t, *pod.Spec.SecurityContext.RunAsUser, int64(2000))
+
+	conf.DashboardSecurityProfile = DashboardSecurityProfileNone
+	conf.DashboardRuntimeClassName = ""
+	backend, _ = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	pod, _ = backend.createPodForDashboard(&models.Dashboard{ID: 1}, &models.DashboardTemplate{})
+	assert.Equal(t, pod.Spec.SecurityContext, nil)
+	assert.Equal(t, pod.Spec.Containers[0].SecurityContext, nil)
+	assert.Equal(t, len(pod.Spec.Volumes), 1)
+	assert.Equal(t, pod.Spec.RuntimeClassName, nil)
+	assert.Equal(t, *pod.Spec.AutomountServiceAccountToken, false)
+
+	conf.DashboardSecurityProfile = "privileged"
+	_, err = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, errors.Is(err, errInvalidSecurityProfile), true)
+}
+
+func TestDashboardPodFromTemplate(t *testing.T) {
+	backend, _ := NewKubernetesDashboardBackendWithClient(
+		&core.Config{DashboardCPULimit: "1"},
+		fake.NewSimpleClientset(),
+		nil,
+	)
+	template := &models.DashboardTemplate{
+		ID:          3,
+		Image:       "tit-dashboard-python:latest",
+		Env:         map[string]string{"TUTORIAL": "python", "LANG": "C.UTF-8"},
+		Port:        6080,
+		MemoryLimit: "2Gi",
+	}
+
+	pod, err := backend.createPodForDashboard(&models.Dashboard{ID: 1}, template)
+	assert.Equal(t, err, nil)
+
+	container := pod.Spec.Containers[0]
+	assert.Equal(t, pod.Labels["template-id"], "3")
+	assert.Equal(t, container.Image, "tit-dashboard-python:latest")
+	assert.Equal(t, container.Env[0].Name, "PASSWORD")
+	assert.Equal(t, container.Env[1], coreV1.EnvVar{Name: "LANG", Value: "C.UTF-8"})
+	assert.Equal(t, container.Env[2], coreV1.EnvVar{Name: "TUTORIAL", Value: "python"})
+	assert.Equal(t, container.Ports[0].ContainerPort, int32(6080))
+	assert.Equal(t, container.Resources.Limits.Memory().String(), "2Gi")
+	assert.Equal(t, container.Resources.Limits.Cpu().IsZero(), true)
+
+	template.CPURequest = "lots"
+	_, err = backend.createPodForDashboard(&models.Dashboard{ID: 1}, template)
+	assert.NotEqual(t, err, nil)
+}
+
+func TestRotateDashboardPasswordAppliesAtOnce(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	dashboard := startTestDashboard(t, dashboardService)
+
+	var podName, passwdFile string
+
+	backend, _ := dashboardService.backend.(*KubernetesDashboardBackend)
+	backend.runCommand = func(ctx context.Context, name string, stdin io.Reader, command ...string) error {
+		content, err := io.ReadAll(stdin)
+		podName, passwdFile = name, string(content)
+
+		assert.Equal(t, command[len(command)-1], "cat > /tmp/tit-dashboard-password.new && "+
+			"mv /tmp/tit-dashboard-password.new /tmp/tit-dashboard-password")
+
+		return err
+	}
+
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := dashboardService.RotateDashboardPassword(context.Background(), dashboard)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, podName, "tit-dashboard-1")
+	assert.Equal(t, passwdFile, dashboard.Password+"\n")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestGrantViewOnlyAccess(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	instructor := &models.User{ID: 2}
+
+	err := dashboardService.GrantViewOnlyAccess(context.Background(), instructor, &models.Dashboard{ID: 1})
+	assert.Equal(t, err, ErrDashboardNotRunning)
+
+	dashboard := startTestDashboard(t, dashboardService)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"dashboards\" AS \"d\" SET \"view_only_password\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO \"dashboard_access_logs\" (.+) VALUES \\(DEFAULT, 1, 1, 2, 'view-only', DEFAULT\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	err = dashboardService.GrantViewOnlyAccess(context.Background(), instructor, dashboard)
+	assert.Equal(t, err, nil)
+	assert.NotEqual(t, dashboard.ViewOnlyPassword, "")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+
+	secret, _ := clientSet.CoreV1().Secrets(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, string(secret.Data["password"]), "secret\n__BEGIN_VIEWONLY__\n"+dashboard.ViewOnlyPassword)
+
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = dashboardService.RotateDashboardPassword(context.Background(), dashboard)
+	assert.Equal(t, err, nil)
+
+	secret, _ = clientSet.CoreV1().Secrets(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, string(secret.Data["password"]), dashboard.Password+"\n__BEGIN_VIEWONLY__\n"+dashboard.ViewOnlyPassword)
+}
+
+func TestGrantViewOnlyAccessRestoresPasswordOnBackendFailure(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+	dashboard := &models.Dashboard{ID: 1, UserID: 1, Status: models.DashboardRunning}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO \"dashboard_access_logs\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE \"dashboards\" AS \"d\" SET \"view_only_password\" = ''").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := dashboardService.GrantViewOnlyAccess(context.Background(), &models.User{ID: 2}, dashboard)
+	assert.Equal(t, err, ErrDashboardNotRunning)
+	assert.Equal(t, dashboard.ViewOnlyPassword, "")
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestGetStudentDashboardSkipsStaff(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\" AS \"d\" " +
+		"WHERE \\(user_id IN \\(SELECT \"u\".\"id\" FROM \"users\" AS \"u\" " +
+		"WHERE \\(NOT is_instructor AND NOT is_super_user\\)\\)\\) AND \\(\"d\".\"id\" = 1\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := dashboardService.GetStudentDashboard(context.Background(), 1)
+	assert.Equal(t, err, ErrDashboardNotFound)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
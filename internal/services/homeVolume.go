@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	coreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errSharedHomeVolume is returned for home volumes with more dashboards per user:
+// the ReadWriteOnce volume cannot be attached to pods on different nodes.
+var errSharedHomeVolume = errors.New("dashboard home volumes require DASHBOARD_LIMIT_PER_USER=1")
+
+// dashboardHomeVolumeWiper is implemented by backends keeping a persistent home
+// volume for every user.
+type dashboardHomeVolumeWiper interface {
+	WipeHomeVolume(ctx context.Context, userID uint64) error
+}
+
+func dashboardHomeVolumeName(userID uint64) string {
+	return fmt.Sprintf("tit-dashboard-home-%d", userID)
+}
+
+// createHomeVolumeForUser builds the claim of the user's home volume. It is labeled
+// apart from the dashboard resources, which are removed on every stop, and is
+// shared by all dashboards of the user.
+func (k *KubernetesDashboardBackend) createHomeVolumeForUser(userID uint64) *coreV1.PersistentVolumeClaim {
+	volumeClaim := &coreV1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: dashboardHomeVolumeName(userID),
+			Labels: map[string]string{
+				"tier":    "dashboard-home",
+				"user-id": strconv.FormatUint(userID, 10),
+			},
+		},
+		Spec: coreV1.PersistentVolumeClaimSpec{
+			AccessModes: []coreV1.PersistentVolumeAccessMode{coreV1.ReadWriteOnce},
+			Resources: coreV1.ResourceRequirements{
+				Requests: coreV1.ResourceList{
+					coreV1.ResourceStorage: *k.homeVolumeSize,
+				},
+			},
+		},
+	}
+
+	if k.conf.DashboardHomeVolumeStorageClass != "" {
+		volumeClaim.Spec.StorageClassName = &k.conf.DashboardHomeVolumeStorageClass
+	}
+
+	return volumeClaim
+}
+
+// ensureHomeVolume creates the home volume claim of the user unless it exists
+// or home volumes are disabled.
+func (k *KubernetesDashboardBackend) ensureHomeVolume(ctx context.Context, userID uint64) error {
+	if k.homeVolumeSize == nil {
+		return nil
+	}
+
+	volumeClaim, err := k.volumeClaimsClient.Get(ctx, dashboardHomeVolumeName(userID), metaV1.GetOptions{})
+	if err == nil && volumeClaim.DeletionTimestamp != nil {
+		return ErrHomeVolumeTerminating
+	}
+
+	if !apiErrors.IsNotFound(err) {
+		return err
+	}
+
+	_, err = k.volumeClaimsClient.Create(ctx, k.createHomeVolumeForUser(userID), metaV1.CreateOptions{})
+
+	return err
+}
+
+// WipeHomeVolume deletes the home volume claim of the user. Kubernetes keeps the
+// claim until the dashboard pod using it is deleted.
+func (k *KubernetesDashboardBackend) WipeHomeVolume(ctx context.Context, userID uint64) error {
+	if k.homeVolumeSize == nil {
+		return ErrHomeVolumesDisabled
+	}
+
+	err := k.volumeClaimsClient.Delete(ctx, dashboardHomeVolumeName(userID), metaV1.DeleteOptions{})
+	if apiErrors.IsNotFound(err) {
+		return ErrHomeVolumeNotFound
+	}
+
+	return err
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDashboardHomeVolume(t *testing.T) {
+	ctx := context.Background()
+	dashboardService, clientSet, mock := setupDashboardService()
+	backend, _ := dashboardService.backend.(*KubernetesDashboardBackend)
+	size := resource.MustParse("1Gi")
+	backend.homeVolumeSize = &size
+	backend.conf.DashboardHomeMountPath = "/root"
+	backend.conf.DashboardWarmPoolSize = 1
+	volumeClaims := clientSet.CoreV1().PersistentVolumeClaims(testNamespace)
+	user := &models.User{ID: 1}
+
+	expectDashboardInsert(mock)
+	expectDashboardRunning(mock)
+	mock.ExpectCommit()
+
+	dashboard, err := dashboardService.CreateDashboard(ctx, user, DashboardStartOptions{})
+	assert.Equal(t, err, nil)
+
+	volumeClaim, err := volumeClaims.Get(ctx, "tit-dashboard-home-1", metaV1.GetOptions{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, volumeClaim.Spec.Resources.Requests.Storage().String(), "1Gi")
+
+	pod, _ := clientSet.CoreV1().Pods(testNamespace).Get(ctx, "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, pod.Spec.Volumes[1].PersistentVolumeClaim.ClaimName, "tit-dashboard-home-1")
+	assert.Equal(t, pod.Spec.Containers[0].VolumeMounts[1].MountPath, "/root")
+
+	expectDashboardStopped(mock)
+
+	_, err = dashboardService.StopDashboard(ctx, dashboard)
+	assert.Equal(t, err, nil)
+
+	_, err = volumeClaims.Get(ctx, "tit-dashboard-home-1", metaV1.GetOptions{})
+	assert.Equal(t, err, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\"").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	err = dashboardService.WipeHomeVolume(ctx, user)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+
+	_, err = volumeClaims.Get(ctx, "tit-dashboard-home-1", metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+	assert.Equal(t, backend.warmPoolEnabled(), false)
+}
+
+func TestCreateDashboardWhileHomeVolumeIsWiped(t *testing.T) {
+	deletedAt := metaV1.Now()
+	volumeClaim := &coreV1.PersistentVolumeClaim{ObjectMeta: metaV1.ObjectMeta{
+		Name:              "tit-dashboard-home-1",
+		Namespace:         testNamespace,
+		DeletionTimestamp: &deletedAt,
+		Finalizers:        []string{"kubernetes.io/pvc-protection"},
+	}}
+	dashboardService, clientSet, mock := setupDashboardService(volumeClaim)
+	backend, _ := dashboardService.backend.(*KubernetesDashboardBackend)
+	size := resource.MustParse("1Gi")
+	backend.homeVolumeSize = &size
+
+	expectDashboardInsert(mock)
+	mock.ExpectExec("DELETE FROM \"dashboards\"").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	_, err := dashboardService.CreateDashboard(context.Background(), &models.User{ID: 1}, DashboardStartOptions{})
+	assert.Equal(t, errors.Is(err, ErrHomeVolumeTerminating), true)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, false)
+}
+
+func TestWipeHomeVolumeWhenDisabled(t *testing.T) {
+	dashboardService, _, mock := setupDashboardService()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := dashboardService.WipeHomeVolume(context.Background(), &models.User{ID: 1})
+	assert.Equal(t, err, ErrHomeVolumesDisabled)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+}
+
+func TestDashboardHomeVolumeWithSeveralDashboardsPerUser(t *testing.T) {
+	conf := &core.Config{
+		KubernetesDashboardNamespace: testNamespace,
+		DashboardHomeVolumeSize:      "1Gi",
+		DashboardLimitPerUser:        2,
+	}
+
+	_, err := NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, errors.Is(err, errSharedHomeVolume), true)
+
+	conf.DashboardLimitPerUser = 1
+
+	_, err = NewKubernetesDashboardBackendWithClient(conf, fake.NewSimpleClientset(), nil)
+	assert.Equal(t, err, nil)
+}
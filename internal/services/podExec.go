@@ -0,0 +1,55 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// podCommandRunner runs the command in the dashboard container of the pod,
+// feeding it stdin.
+type podCommandRunner func(ctx context.Context, podName string, stdin io.Reader, command ...string) error
+
+// newPodCommandRunner runs commands through the exec subresource of the pods in
+// the namespace.
+func newPodCommandRunner(
+	clientConfig *rest.Config,
+	clientSet kubernetes.Interface,
+	namespace string,
+) podCommandRunner {
+	return func(ctx context.Context, podName string, stdin io.Reader, command ...string) error {
+		request := clientSet.CoreV1().RESTClient().Post().
+			Resource("pods").
+			Namespace(namespace).
+			Name(podName).
+			SubResource("exec").
+			VersionedParams(&coreV1.PodExecOptions{
+				Container: "dashboard",
+				Command:   command,
+				Stdin:     stdin != nil,
+				Stderr:    true,
+			}, scheme.ParameterCodec)
+
+		executor, err := remotecommand.NewSPDYExecutor(clientConfig, "POST", request.URL())
+		if err != nil {
+			return err
+		}
+
+		stderr := new(bytes.Buffer)
+
+		err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: stdin, Stderr: stderr})
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	// DashboardSecurityProfileRestricted runs dashboards as an unprivileged user
+	// without capabilities, under the default seccomp profile of the runtime.
+	DashboardSecurityProfileRestricted = "restricted"
+	// DashboardSecurityProfileNone keeps the defaults of the image and the runtime,
+	// for images which need root.
+	DashboardSecurityProfileNone = "none"
+
+	// dashboardImageUser is the unprivileged user of the dashboard image.
+	dashboardImageUser = 1000
+)
+
+var errInvalidSecurityProfile = errors.New("invalid dashboard security profile")
+
+// dashboardTmpPath is written by Xvfb, x11vnc and the dashboard entrypoint.
+const dashboardTmpPath = "/tmp"
+
+// dashboardSecurityContexts returns the pod and dashboard container security
+// contexts of the configured profile, both nil for DashboardSecurityProfileNone.
+// Dashboards run as the user of the dashboard image unless another one is set.
+func dashboardSecurityContexts(conf *core.Config) (*coreV1.PodSecurityContext, *coreV1.SecurityContext, error) {
+	switch conf.DashboardSecurityProfile {
+	case DashboardSecurityProfileNone:
+		return nil, nil, nil
+	case "", DashboardSecurityProfileRestricted:
+	default:
+		return nil, nil, fmt.Errorf("%w %q", errInvalidSecurityProfile, conf.DashboardSecurityProfile)
+	}
+
+	userID := int64(dashboardImageUser)
+
+	switch {
+	case conf.DashboardRunAsUser < 0:
+		return nil, nil, fmt.Errorf("%w %q: invalid user %d", errInvalidSecurityProfile,
+			conf.DashboardSecurityProfile, conf.DashboardRunAsUser)
+	case conf.DashboardRunAsUser > 0:
+		userID = int64(conf.DashboardRunAsUser)
+	}
+
+	podSecurityContext := &coreV1.PodSecurityContext{
+		RunAsNonRoot: pointer.Bool(true),
+		RunAsUser:    pointer.Int64(userID),
+		RunAsGroup:   pointer.Int64(userID),
+		// Makes home volumes writable by the dashboard user.
+		FSGroup:        pointer.Int64(userID),
+		SeccompProfile: &coreV1.SeccompProfile{Type: coreV1.SeccompProfileTypeRuntimeDefault},
+	}
+	containerSecurityContext := &coreV1.SecurityContext{
+		AllowPrivilegeEscalation: pointer.Bool(false),
+		Capabilities:             &coreV1.Capabilities{Drop: []coreV1.Capability{"ALL"}},
+		// The paths the dashboard writes to are mounted by dashboardWritableVolumes.
+		ReadOnlyRootFilesystem: pointer.Bool(true),
+	}
+
+	return podSecurityContext, containerSecurityContext, nil
+}
+
+// dashboardWritableVolumes returns the emptyDir volumes and their mounts which
+// keep dashboards working on a read-only root filesystem: the temporary directory
+// and the home directory, where e.g. fluxbox keeps its settings. Home volumes
+// replace the latter. Nothing is returned unless the root filesystem is read-only.
+func dashboardWritableVolumes(
+	containerSecurityContext *coreV1.SecurityContext,
+	homePath string,
+) ([]coreV1.Volume, []coreV1.VolumeMount) {
+	if containerSecurityContext == nil || !pointer.BoolDeref(containerSecurityContext.ReadOnlyRootFilesystem, false) {
+		return nil, nil
+	}
+
+	emptyDir := coreV1.VolumeSource{EmptyDir: &coreV1.EmptyDirVolumeSource{}}
+	volumes := []coreV1.Volume{
+		{Name: "home", VolumeSource: emptyDir},
+		{Name: "tmp", VolumeSource: emptyDir},
+	}
+	mounts := []coreV1.VolumeMount{
+		{Name: "home", MountPath: homePath},
+		{Name: "tmp", MountPath: dashboardTmpPath},
+	}
+
+	return volumes, mounts
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+// DashboardReaper periodically stops dashboards which had no activity for longer
+// than the configured idle timeout.
+type DashboardReaper struct {
+	dashboardService *DashboardService
+	logger           *core.Logger
+	idleTimeout      time.Duration
+	interval         time.Duration
+}
+
+func NewDashboardReaper(
+	dashboardService *DashboardService,
+	logger *core.Logger,
+	conf *core.Config,
+) *DashboardReaper {
+	return &DashboardReaper{
+		dashboardService: dashboardService,
+		logger:           logger,
+		idleTimeout:      time.Duration(conf.DashboardIdleTimeoutMinutes) * time.Minute,
+		interval:         time.Duration(conf.DashboardReaperIntervalSeconds) * time.Second,
+	}
+}
+
+// Run blocks until ctx is done, reaping idle dashboards every interval.
+func (r *DashboardReaper) Run(ctx context.Context) {
+	if r.idleTimeout <= 0 || r.interval <= 0 {
+		r.logger.Info().Msg("Dashboard reaper is disabled")
+
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reap(ctx, time.Now()); err != nil {
+				r.logger.Err(err).Msg("dashboard reaping")
+			}
+		}
+	}
+}
+
+// reap stops the running dashboards which had no activity since idleTimeout before now.
+func (r *DashboardReaper) reap(ctx context.Context, now time.Time) error {
+	var dashboards []*models.Dashboard
+
+	err := r.dashboardService.db.NewSelect().
+		Model(&dashboards).
+		Where("status = ?", models.DashboardRunning).
+		Where("last_activity_at < ?", now.Add(-r.idleTimeout)).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	for _, dashboard := range dashboards {
+		logger := r.logger.With().Uint64("dashboardId", dashboard.ID).Uint64("userId", dashboard.UserID).Logger()
+		idle := now.Sub(dashboard.LastActivityAt)
+
+		result, err := r.dashboardService.StopDashboard(ctx, dashboard)
+		if err != nil {
+			logger.Err(err).Msg("idle dashboard stopping")
+
+			continue
+		}
+
+		logger.Info().
+			Dur("idle", idle).
+			Strs("deleted", result.Deleted).
+			Msg("Idle dashboard reaped")
+
+		r.dashboardService.events.publish(dashboard.UserID, DashboardEvent{
+			DashboardID: dashboard.ID,
+			Type:        DashboardEventReaped,
+			Time:        time.Now(),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+)
+
+func TestReapIdleDashboards(t *testing.T) {
+	dashboardService, clientSet, mock := setupDashboardService()
+	conf := &core.Config{DashboardIdleTimeoutMinutes: 60, DashboardReaperIntervalSeconds: 60}
+	reaper := NewDashboardReaper(dashboardService, core.NewLogger(conf), conf)
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	dashboard := startTestDashboard(t, dashboardService)
+
+	events, unsubscribe := dashboardService.SubscribeDashboardEvents(&models.User{ID: 1})
+	defer unsubscribe()
+
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\" AS \"d\" " +
+		"WHERE \\(status = 'running'\\) AND \\(last_activity_at < '2023-07-01 11:00:00\\+00:00'\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "last_activity_at"}).
+			AddRow(dashboard.ID, dashboard.UserID, models.DashboardRunning, now.Add(-2*time.Hour)))
+	expectDashboardStopped(mock)
+
+	err := reaper.reap(context.Background(), now)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, false)
+
+	event := <-events
+	assert.Equal(t, event.DashboardID, dashboard.ID)
+	assert.Equal(t, event.Type, DashboardEventReaped)
+}
+
+func TestDashboardReaperDisabledWithoutIdleTimeout(t *testing.T) {
+	dashboardService, _, _ := setupDashboardService()
+	conf := &core.Config{DashboardReaperIntervalSeconds: 60}
+
+	// Run returns at once instead of reaping until the context is done.
+	NewDashboardReaper(dashboardService, core.NewLogger(conf), conf).Run(context.Background())
+}
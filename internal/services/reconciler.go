@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"time"
+
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	"github.com/uptrace/bun"
+)
+
+// reconcileGracePeriod protects dashboards being started right now, whose
+// resources are still being created.
+const reconcileGracePeriod = 2 * time.Minute
+
+// reconcilerMetrics count the runs of the reconciler and the resources it fixed.
+// They are only served to super users, so they are not published by expvar.
+var reconcilerMetrics = new(expvar.Map)
+
+// ReconcilerMetrics returns the counters of the dashboard reconciler by name.
+func ReconcilerMetrics() map[string]int64 {
+	metrics := make(map[string]int64)
+
+	reconcilerMetrics.Do(func(kv expvar.KeyValue) {
+		if counter, ok := kv.Value.(*expvar.Int); ok {
+			metrics[kv.Key] = counter.Value()
+		}
+	})
+
+	return metrics
+}
+
+// DashboardReconciler periodically brings the dashboard resources in the cluster
+// in line with the dashboards table: orphaned resources are deleted, missing
+// resources of live dashboards are recreated and dashboards which lost their
+// pods are marked as stopped.
+type DashboardReconciler struct {
+	dashboardService *DashboardService
+	logger           *core.Logger
+	interval         time.Duration
+}
+
+func NewDashboardReconciler(
+	dashboardService *DashboardService,
+	logger *core.Logger,
+	conf *core.Config,
+) *DashboardReconciler {
+	return &DashboardReconciler{
+		dashboardService: dashboardService,
+		logger:           logger,
+		interval:         time.Duration(conf.DashboardReconcileIntervalSeconds) * time.Second,
+	}
+}
+
+// Run blocks until ctx is done, reconciling dashboards every interval.
+func (r *DashboardReconciler) Run(ctx context.Context) {
+	backend, ok := r.dashboardService.backend.(*KubernetesDashboardBackend)
+	if !ok || r.interval <= 0 {
+		r.logger.Info().Msg("Dashboard reconciler is disabled")
+
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reconcilerMetrics.Add("runs", 1)
+
+			if err := r.reconcile(ctx, backend); err != nil {
+				reconcilerMetrics.Add("errors", 1)
+				r.logger.Err(err).Msg("dashboard reconciling")
+			}
+		}
+	}
+}
+
+func (r *DashboardReconciler) reconcile(ctx context.Context, backend *KubernetesDashboardBackend) error {
+	sets, err := backend.listResources(ctx)
+	if err != nil {
+		return err
+	}
+
+	dashboardIDs := make([]uint64, 0, len(sets))
+	for dashboardID := range sets {
+		dashboardIDs = append(dashboardIDs, dashboardID)
+	}
+
+	var dashboards []*models.Dashboard
+
+	// Dashboards which are not stopped are loaded too, their resources might be gone.
+	query := r.dashboardService.db.NewSelect().
+		Model(&dashboards).
+		Where("status <> ?", models.DashboardStopped)
+	if len(dashboardIDs) != 0 {
+		query = query.WhereOr("id IN (?)", bun.In(dashboardIDs))
+	}
+
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	dashboardsByID := make(map[uint64]*models.Dashboard, len(dashboards))
+	for _, dashboard := range dashboards {
+		dashboardsByID[dashboard.ID] = dashboard
+	}
+
+	for dashboardID, set := range sets {
+		if time.Since(set.newest) < reconcileGracePeriod {
+			continue
+		}
+
+		r.reconcileDashboard(ctx, backend, dashboardID, dashboardsByID[dashboardID], set)
+	}
+
+	for _, dashboard := range dashboards {
+		_, hasResources := sets[dashboard.ID]
+		if hasResources || dashboard.Status == models.DashboardStopped ||
+			time.Since(dashboard.CreatedAt) < reconcileGracePeriod {
+			continue
+		}
+
+		r.reconcileDashboard(ctx, backend, dashboard.ID, dashboard, &dashboardResourceSet{kinds: map[string]bool{}})
+	}
+
+	return nil
+}
+
+func (r *DashboardReconciler) reconcileDashboard(
+	ctx context.Context,
+	backend *KubernetesDashboardBackend,
+	dashboardID uint64,
+	dashboard *models.Dashboard,
+	set *dashboardResourceSet,
+) {
+	logger := r.logger.With().Uint64("dashboardId", dashboardID).Logger()
+
+	switch {
+	case dashboard == nil || dashboard.Status == models.DashboardStopped:
+		result, err := backend.Stop(ctx, dashboardID)
+		if err != nil {
+			reconcilerMetrics.Add("errors", 1)
+			logger.Err(err).Msg("orphaned dashboard deleting")
+
+			return
+		}
+
+		reconcilerMetrics.Add("deleted", int64(len(result.Deleted)))
+		logger.Info().Strs("deleted", result.Deleted).Msg("Orphaned dashboard resources deleted")
+	case set.pod == nil || set.pod.DeletionTimestamp != nil:
+		result, err := r.dashboardService.StopDashboard(ctx, dashboard)
+		if err != nil {
+			reconcilerMetrics.Add("errors", 1)
+			logger.Err(err).Msg("half-created dashboard deleting")
+
+			return
+		}
+
+		reconcilerMetrics.Add("deleted", int64(len(result.Deleted)))
+		logger.Info().Strs("deleted", result.Deleted).Msg("Dashboard without pod stopped")
+	default:
+		restored, err := backend.restoreMissing(ctx, dashboard, set.kinds)
+		reconcilerMetrics.Add("recreated", int64(len(restored)))
+
+		if err != nil {
+			reconcilerMetrics.Add("errors", 1)
+			logger.Err(err).Msg("dashboard resources restoring")
+
+			return
+		}
+
+		if len(restored) != 0 {
+			logger.Info().Strs("recreated", restored).Msg("Missing dashboard resources recreated")
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/assert/v2"
+	"github.com/tutorin-tech/tit-backend/internal/core"
+	"github.com/tutorin-tech/tit-backend/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileDashboards(t *testing.T) {
+	createdAt := metaV1.NewTime(time.Now().Add(-time.Hour))
+	livePod := &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Name:              "tit-dashboard-1",
+		Namespace:         testNamespace,
+		Labels:            dashboardLabels(1),
+		CreationTimestamp: createdAt,
+	}}
+	orphanedService := &coreV1.Service{ObjectMeta: metaV1.ObjectMeta{
+		Name:              "tit-dashboard-2",
+		Namespace:         testNamespace,
+		Labels:            dashboardLabels(2),
+		CreationTimestamp: createdAt,
+	}}
+
+	dashboardService, clientSet, mock := setupDashboardService(livePod, orphanedService)
+	reconciler := NewDashboardReconciler(dashboardService, core.NewLogger(&core.Config{}), &core.Config{})
+	backend, _ := dashboardService.backend.(*KubernetesDashboardBackend)
+
+	mock.ExpectQuery("SELECT (.+) FROM \"dashboards\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "password", "created_at"}).
+			AddRow(1, 1, models.DashboardRunning, "secret", createdAt.Time))
+
+	err := reconciler.reconcile(context.Background(), backend)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, mock.ExpectationsWereMet(), nil)
+	assertDashboardResources(t, clientSet, true)
+
+	secret, _ := clientSet.CoreV1().Secrets(testNamespace).Get(context.Background(), "tit-dashboard-1", metaV1.GetOptions{})
+	assert.Equal(t, string(secret.Data["password"]), "secret")
+
+	_, err = clientSet.CoreV1().Services(testNamespace).Get(context.Background(), "tit-dashboard-2", metaV1.GetOptions{})
+	assert.NotEqual(t, err, nil)
+
+	metrics := ReconcilerMetrics()
+	assert.Equal(t, metrics["deleted"] > 0, true)
+	assert.Equal(t, metrics["recreated"] > 0, true)
+}
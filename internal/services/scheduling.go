@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var errInvalidToleration = errors.New("invalid toleration")
+
+// DashboardResources are compute resources of a dashboard container in Kubernetes
+// quantity notation, e.g. "500m" CPU or "1Gi" memory. Empty values are not set.
+type DashboardResources struct {
+	CPURequest    string `json:"cpuRequest,omitempty"`
+	CPULimit      string `json:"cpuLimit,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+}
+
+// ResourceRequirements parses the quantities, failing on the first malformed one.
+func (r DashboardResources) ResourceRequirements() (coreV1.ResourceRequirements, error) {
+	requirements := coreV1.ResourceRequirements{
+		Requests: coreV1.ResourceList{},
+		Limits:   coreV1.ResourceList{},
+	}
+
+	quantities := []struct {
+		list  coreV1.ResourceList
+		name  coreV1.ResourceName
+		value string
+	}{
+		{requirements.Requests, coreV1.ResourceCPU, r.CPURequest},
+		{requirements.Limits, coreV1.ResourceCPU, r.CPULimit},
+		{requirements.Requests, coreV1.ResourceMemory, r.MemoryRequest},
+		{requirements.Limits, coreV1.ResourceMemory, r.MemoryLimit},
+	}
+
+	for _, quantity := range quantities {
+		if quantity.value == "" {
+			continue
+		}
+
+		parsed, err := resource.ParseQuantity(quantity.value)
+		if err != nil {
+			return requirements, fmt.Errorf("%s %q: %w", quantity.name, quantity.value, err)
+		}
+
+		quantity.list[quantity.name] = parsed
+	}
+
+	return requirements, nil
+}
+
+// parseTolerations parses tolerations written like taints for kubectl:
+// "key=value:Effect", "key:Effect" or "key=value" to tolerate every effect.
+func parseTolerations(items []string) ([]coreV1.Toleration, error) {
+	tolerations := make([]coreV1.Toleration, 0, len(items))
+
+	for _, item := range items {
+		toleration := coreV1.Toleration{Operator: coreV1.TolerationOpExists}
+
+		keyValue, effect, hasEffect := strings.Cut(item, ":")
+		if hasEffect {
+			toleration.Effect = coreV1.TaintEffect(effect)
+
+			switch toleration.Effect {
+			case coreV1.TaintEffectNoSchedule, coreV1.TaintEffectPreferNoSchedule, coreV1.TaintEffectNoExecute:
+			default:
+				return nil, fmt.Errorf("%w %q: unknown effect %q", errInvalidToleration, item, effect)
+			}
+		}
+
+		key, value, hasValue := strings.Cut(keyValue, "=")
+		if key == "" {
+			return nil, fmt.Errorf("%w %q: empty key", errInvalidToleration, item)
+		}
+
+		toleration.Key = key
+
+		if hasValue {
+			toleration.Operator = coreV1.TolerationOpEqual
+			toleration.Value = value
+		}
+
+		tolerations = append(tolerations, toleration)
+	}
+
+	return tolerations, nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 func getErrorMessageForEnv(key string, value string) string {
@@ -43,3 +44,63 @@ func GetEnvOrDefault(key string, defaultValue string) string {
 
 	return value
 }
+
+// GetEnvListOrDefault splits a comma separated environment variable, skipping empty items.
+func GetEnvListOrDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	items := make([]string, 0)
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// GetEnvMapOrDefault parses an environment variable of "key=value,key=value" form.
+func GetEnvMapOrDefault(key string, defaultValue map[string]string) map[string]string {
+	items := GetEnvListOrDefault(key, nil)
+	if items == nil {
+		return defaultValue
+	}
+
+	result := make(map[string]string, len(items))
+
+	for _, item := range items {
+		itemKey, itemValue, found := strings.Cut(item, "=")
+		if !found {
+			log.Fatal(getErrorMessageForEnv(key, os.Getenv(key)))
+		}
+
+		result[strings.TrimSpace(itemKey)] = strings.TrimSpace(itemValue)
+	}
+
+	return result
+}
+
+// GetEnvIntMapOrDefault parses an environment variable of "key=1,key=2" form.
+func GetEnvIntMapOrDefault(key string, defaultValue map[string]int) map[string]int {
+	items := GetEnvMapOrDefault(key, nil)
+	if items == nil {
+		return defaultValue
+	}
+
+	result := make(map[string]int, len(items))
+
+	for itemKey, itemValue := range items {
+		intValue, err := strconv.Atoi(itemValue)
+		if err != nil {
+			log.Fatal(getErrorMessageForEnv(key, os.Getenv(key)))
+		}
+
+		result[itemKey] = intValue
+	}
+
+	return result
+}
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN dashboard_last_activity_at;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN dashboard_last_activity_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+ALTER TABLE tutorials DROP COLUMN dashboard_template_id;
+
+DROP TABLE dashboard_templates;
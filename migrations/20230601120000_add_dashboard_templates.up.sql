@@ -0,0 +1,14 @@
+CREATE TABLE dashboard_templates (
+    id INT NOT NULL GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    image VARCHAR(256) NOT NULL,
+    env JSONB,
+    port INT NOT NULL DEFAULT 0,
+    cpu_request VARCHAR(16) NOT NULL DEFAULT '',
+    cpu_limit VARCHAR(16) NOT NULL DEFAULT '',
+    memory_request VARCHAR(16) NOT NULL DEFAULT '',
+    memory_limit VARCHAR(16) NOT NULL DEFAULT ''
+);
+
+ALTER TABLE tutorials
+    ADD COLUMN dashboard_template_id INT REFERENCES dashboard_templates (id) ON DELETE SET NULL;
@@ -0,0 +1,14 @@
+ALTER TABLE users ADD COLUMN dashboard_password VARCHAR(64) DEFAULT '';
+ALTER TABLE users ADD COLUMN dashboard_last_activity_at TIMESTAMPTZ;
+
+UPDATE users
+SET dashboard_password = d.password, dashboard_last_activity_at = d.last_activity_at
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, password, last_activity_at
+    FROM dashboards
+    WHERE status <> 'stopped'
+    ORDER BY user_id, created_at DESC
+) AS d
+WHERE users.id = d.user_id;
+
+DROP TABLE dashboards;
@@ -0,0 +1,27 @@
+CREATE TABLE dashboards (
+    id INT NOT NULL GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INT NOT NULL,
+    template_id INT REFERENCES dashboard_templates (id) ON DELETE SET NULL,
+    status VARCHAR(16) NOT NULL,
+    password VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_activity_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    stopped_at TIMESTAMPTZ
+);
+
+CREATE INDEX dashboards_user_id_status_idx ON dashboards (user_id, status);
+
+-- Resources of running dashboards are named after their users, so they keep user IDs.
+INSERT INTO dashboards (id, user_id, status, password, last_activity_at)
+SELECT id, id, 'running', dashboard_password, COALESCE(dashboard_last_activity_at, now())
+FROM users
+WHERE dashboard_password <> '';
+
+SELECT setval(
+    pg_get_serial_sequence('dashboards', 'id'),
+    (SELECT COALESCE(MAX(id), 0) + 1 FROM dashboards),
+    false
+);
+
+ALTER TABLE users DROP COLUMN dashboard_password;
+ALTER TABLE users DROP COLUMN dashboard_last_activity_at;
@@ -0,0 +1,4 @@
+DROP TABLE dashboard_access_logs;
+
+ALTER TABLE dashboards DROP COLUMN view_only_password;
+ALTER TABLE users DROP COLUMN is_instructor;
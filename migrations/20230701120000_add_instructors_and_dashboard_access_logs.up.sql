@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN is_instructor BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE dashboards ADD COLUMN view_only_password VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE TABLE dashboard_access_logs (
+    id INT NOT NULL GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    dashboard_id INT NOT NULL REFERENCES dashboards (id) ON DELETE CASCADE,
+    student_id INT NOT NULL,
+    instructor_id INT NOT NULL,
+    access VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX dashboard_access_logs_student_id_idx ON dashboard_access_logs (student_id);
@@ -0,0 +1,3 @@
+DROP TABLE dashboard_usages;
+
+ALTER TABLE users DROP COLUMN dashboard_quota_hours;
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN dashboard_quota_hours INT;
+
+CREATE TABLE dashboard_usages (
+    id INT NOT NULL GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    dashboard_id INT NOT NULL REFERENCES dashboards (id) ON DELETE CASCADE,
+    user_id INT NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    stopped_at TIMESTAMPTZ
+);
+
+CREATE INDEX dashboard_usages_user_id_started_at_idx ON dashboard_usages (user_id, started_at);
+
+-- Dashboards started so far ran from their creation until they stopped.
+INSERT INTO dashboard_usages (dashboard_id, user_id, started_at, stopped_at)
+SELECT id, user_id, created_at, stopped_at
+FROM dashboards
+WHERE status <> 'starting';